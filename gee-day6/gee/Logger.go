@@ -1,15 +1,179 @@
 package gee
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 )
 
+//终端输出使用的ANSI颜色
+const (
+	green   = "\033[97;42m"
+	white   = "\033[90;47m"
+	yellow  = "\033[90;43m"
+	red     = "\033[97;41m"
+	blue    = "\033[97;44m"
+	magenta = "\033[97;45m"
+	cyan    = "\033[97;46m"
+	reset   = "\033[0m"
+)
+
+//LogFormatter 定义日志格式化函数 返回一行日志
+type LogFormatter func(params LogFormatterParams) string
+
+//LogFormatterParams 格式化日志时可以使用的请求信息
+type LogFormatterParams struct {
+	Request *http.Request
+	// TimeStamp 请求处理完成的时间
+	TimeStamp time.Time
+	// StatusCode 响应状态码
+	StatusCode int
+	// Latency 处理请求花费的时间
+	Latency time.Duration
+	// ClientIP 客户端IP 见Context.ClientIP
+	ClientIP string
+	// RequestID RequestID中间件生成或沿用的请求ID 没有使用中间件时为空
+	RequestID string
+	// Method 请求方法
+	Method string
+	// Path 请求路径 包括URL参数 敏感参数已经按engine的RedactionPolicy遮盖
+	Path string
+	// ErrorMessage 处理请求过程中通过c.Error记录的错误
+	ErrorMessage string
+	// BodySize 响应体的字节数
+	BodySize int
+	// Keys 处理请求时通过c.Set保存的数据
+	Keys map[string]interface{}
+
+	isTerm bool
+}
+
+//StatusCodeColor 根据状态码返回颜色
+func (p *LogFormatterParams) StatusCodeColor() string {
+	code := p.StatusCode
+	switch {
+	case code >= http.StatusOK && code < http.StatusMultipleChoices:
+		return green
+	case code >= http.StatusMultipleChoices && code < http.StatusBadRequest:
+		return white
+	case code >= http.StatusBadRequest && code < http.StatusInternalServerError:
+		return yellow
+	default:
+		return red
+	}
+}
+
+//MethodColor 根据请求方法返回颜色
+func (p *LogFormatterParams) MethodColor() string {
+	switch p.Method {
+	case http.MethodGet:
+		return blue
+	case http.MethodPost:
+		return cyan
+	case http.MethodPut:
+		return yellow
+	case http.MethodDelete:
+		return red
+	case http.MethodPatch:
+		return green
+	case http.MethodHead:
+		return magenta
+	default:
+		return reset
+	}
+}
+
+//ResetColor 返回重置颜色的控制字符
+func (p *LogFormatterParams) ResetColor() string {
+	return reset
+}
+
+//IsOutputColor 输出是否是终端 只有终端才输出颜色
+func (p *LogFormatterParams) IsOutputColor() bool {
+	return p.isTerm
+}
+
+//defaultLogFormatter 默认的日志格式 例如：[GEE] 2023/01/12 - 15:04:05 | 200 | 1.2ms | 127.0.0.1 | GET "/hello"
+//使用RequestID中间件时在客户端IP后面加上请求ID
+var defaultLogFormatter = func(param LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	requestID := ""
+	if param.RequestID != "" {
+		requestID = " " + param.RequestID + " |"
+	}
+	line := fmt.Sprintf("[GEE] %v |%s %3d %s| %13v | %15s |%s%s %-7s %s %#v\n",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		requestID,
+		methodColor, param.Method, resetColor,
+		param.Path,
+	)
+	if param.ErrorMessage != "" {
+		line += param.ErrorMessage + "\n"
+	}
+	return line
+}
+
+//LoggerConfig 定义Logger中间件的配置
+type LoggerConfig struct {
+	// Formatter 日志格式化函数 为nil时使用默认格式
+	Formatter LogFormatter
+	// Output 日志输出 为nil时输出到log包的输出(默认是标准错误)
+	Output io.Writer
+	// SkipPaths 不记录日志的路径 例如健康检查 "/healthz"
+	SkipPaths []string
+}
+
 // Logger 定义Logger函数  用于记录请求和响应
 func Logger() HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{})
+}
+
+//LoggerWithWriter 定义输出到指定Writer的Logger中间件
+func LoggerWithWriter(out io.Writer, skipPaths ...string) HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{Output: out, SkipPaths: skipPaths})
+}
+
+//LoggerWithFormatter 定义使用自定义格式的Logger中间件
+func LoggerWithFormatter(formatter LogFormatter) HandlerFunc {
+	return LoggerWithConfig(LoggerConfig{Formatter: formatter})
+}
+
+//LoggerWithConfig 定义使用自定义配置的Logger中间件
+func LoggerWithConfig(config LoggerConfig) HandlerFunc {
+	formatter := config.Formatter
+	if formatter == nil {
+		formatter = defaultLogFormatter
+	}
+	out := config.Output
+	if out == nil {
+		out = log.Writer()
+	}
+	isTerm := isTerminal(out)
+	skip := make(map[string]bool, len(config.SkipPaths))
+	for _, path := range config.SkipPaths {
+		skip[path] = true
+	}
+
 	return func(c *Context) {
 		// Start timer 开始计时
 		t := time.Now()
+		path := c.Req.URL.Path
+		raw := c.Req.URL.RawQuery
 		// Process request 	 处理请求 执行下一个中间件 也就是下一个路由
 
 		/**
@@ -23,7 +187,54 @@ func Logger() HandlerFunc {
 		*/
 
 		c.Next()
+		if skip[path] {
+			return
+		}
+		if raw != "" {
+			path = path + "?" + c.engine.Redaction().RedactQuery(raw)
+		}
 		// Calculate resolution time  计算处理时间
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		param := LogFormatterParams{
+			Request:      c.Req,
+			TimeStamp:    time.Now(),
+			StatusCode:   c.Writer.Status(),
+			ClientIP:     c.ClientIP(),
+			RequestID:    c.RequestID(),
+			Method:       c.Method,
+			Path:         path,
+			ErrorMessage: joinErrors(c.Errors),
+			BodySize:     c.Writer.Size(),
+			isTerm:       isTerm,
+		}
+		param.Latency = param.TimeStamp.Sub(t)
+		c.mu.RLock()
+		param.Keys = c.Keys
+		c.mu.RUnlock()
+		fmt.Fprint(out, formatter(param))
+	}
+}
+
+//joinErrors 把多个错误拼接成一行
+func joinErrors(errs []error) string {
+	if len(errs) == 0 {
+		return ""
+	}
+	messages := make([]string, 0, len(errs))
+	for i, err := range errs {
+		messages = append(messages, fmt.Sprintf("Error #%02d: %s", i+1, err))
+	}
+	return strings.Join(messages, "\n")
+}
+
+//isTerminal 判断输出是否是终端 设置了NO_COLOR环境变量时不输出颜色
+func isTerminal(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
 	}
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
 }
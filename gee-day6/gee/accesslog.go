@@ -0,0 +1,221 @@
+package gee
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Apache/NCSA 访问日志格式
+const (
+	CommonLogFormat   = `%h %l %u %t "%r" %>s %b`
+	CombinedLogFormat = `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i"`
+)
+
+//AccessLogConfig 定义AccessLog中间件的配置
+type AccessLogConfig struct {
+	// Format 日志格式 使用Apache的格式指令 为空时使用CombinedLogFormat
+	// 支持 %h %a %l %u %t %r %s %>s %b %B %D %T %m %U %q %H %% %{Header}i %{Header}o
+	Format string
+	// Output 日志输出 例如 &gee.RotatingFile{...} 为nil时输出到标准输出
+	Output io.Writer
+}
+
+//accessLogEntry 格式化一行日志时需要的信息
+type accessLogEntry struct {
+	c       *Context
+	start   time.Time
+	latency time.Duration
+}
+
+//accessLogToken 格式中的一个指令或者一段原样输出的文本
+type accessLogToken func(sb *strings.Builder, e *accessLogEntry)
+
+//AccessLog 定义输出Apache格式访问日志的中间件 与Logger互不影响
+func AccessLog(config AccessLogConfig) HandlerFunc {
+	format := config.Format
+	if format == "" {
+		format = CombinedLogFormat
+	}
+	out := config.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	tokens, err := parseAccessLogFormat(format)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *Context) {
+		start := time.Now()
+		c.Next()
+		entry := &accessLogEntry{c: c, start: start, latency: time.Since(start)}
+		var sb strings.Builder
+		for _, token := range tokens {
+			token(&sb, entry)
+		}
+		sb.WriteByte('\n')
+		//一行日志只调用一次Write 并发写入时不会交错
+		io.WriteString(out, sb.String())
+	}
+}
+
+//parseAccessLogFormat 把格式预先解析成指令列表 避免每个请求都重新解析
+func parseAccessLogFormat(format string) ([]accessLogToken, error) {
+	tokens := make([]accessLogToken, 0)
+	literal := strings.Builder{}
+	flush := func() {
+		if literal.Len() > 0 {
+			text := literal.String()
+			tokens = append(tokens, func(sb *strings.Builder, _ *accessLogEntry) { sb.WriteString(text) })
+			literal.Reset()
+		}
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+		i++
+		if i >= len(format) {
+			return nil, fmt.Errorf("gee: access log format ends with %%")
+		}
+		//%{Name}i 这种带参数的指令
+		arg := ""
+		if format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("gee: unclosed { in access log format %q", format)
+			}
+			arg = format[i+1 : i+end]
+			i += end + 1
+			if i >= len(format) {
+				return nil, fmt.Errorf("gee: missing directive after %%{%s}", arg)
+			}
+		}
+		//%>s 表示最终的状态码 gee中只有一个状态码
+		if format[i] == '>' && i+1 < len(format) {
+			i++
+		}
+		if format[i] == '%' {
+			literal.WriteByte('%')
+			continue
+		}
+		token, err := accessLogDirective(format[i], arg)
+		if err != nil {
+			return nil, err
+		}
+		flush()
+		tokens = append(tokens, token)
+	}
+	flush()
+	return tokens, nil
+}
+
+func accessLogDirective(directive byte, arg string) (accessLogToken, error) {
+	switch directive {
+	case 'h', 'a':
+		return func(sb *strings.Builder, e *accessLogEntry) { sb.WriteString(e.c.ClientIP()) }, nil
+	case 'l':
+		return func(sb *strings.Builder, e *accessLogEntry) { sb.WriteByte('-') }, nil
+	case 'u':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			user, _, ok := e.c.Req.BasicAuth()
+			if !ok || user == "" {
+				user = "-"
+			}
+			sb.WriteString(escapeLogValue(user))
+		}, nil
+	case 't':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			sb.WriteString(e.start.Format("[02/Jan/2006:15:04:05 -0700]"))
+		}, nil
+	case 'r':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			req := e.c.Req
+			uri := e.c.engine.Redaction().RedactURI(req.URL.RequestURI())
+			sb.WriteString(escapeLogValue(req.Method + " " + uri + " " + req.Proto))
+		}, nil
+	case 's':
+		return func(sb *strings.Builder, e *accessLogEntry) { sb.WriteString(strconv.Itoa(e.c.Writer.Status())) }, nil
+	case 'b':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			if size := e.c.Writer.Size(); size > 0 {
+				sb.WriteString(strconv.Itoa(size))
+			} else {
+				sb.WriteByte('-')
+			}
+		}, nil
+	case 'B':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			size := e.c.Writer.Size()
+			if size < 0 {
+				size = 0
+			}
+			sb.WriteString(strconv.Itoa(size))
+		}, nil
+	case 'D':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			sb.WriteString(strconv.FormatInt(e.latency.Microseconds(), 10))
+		}, nil
+	case 'T':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			sb.WriteString(strconv.FormatInt(int64(e.latency/time.Second), 10))
+		}, nil
+	case 'm':
+		return func(sb *strings.Builder, e *accessLogEntry) { sb.WriteString(escapeLogValue(e.c.Req.Method)) }, nil
+	case 'U':
+		return func(sb *strings.Builder, e *accessLogEntry) { sb.WriteString(escapeLogValue(e.c.Req.URL.Path)) }, nil
+	case 'q':
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			if raw := e.c.Req.URL.RawQuery; raw != "" {
+				sb.WriteString("?" + escapeLogValue(e.c.engine.Redaction().RedactQuery(raw)))
+			}
+		}, nil
+	case 'H':
+		return func(sb *strings.Builder, e *accessLogEntry) { sb.WriteString(escapeLogValue(e.c.Req.Proto)) }, nil
+	case 'i':
+		if arg == "" {
+			return nil, fmt.Errorf("gee: %%i requires a header name")
+		}
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			sb.WriteString(headerOrDash(e.c.engine.Redaction().RedactHeader(arg, e.c.Req.Header.Get(arg))))
+		}, nil
+	case 'o':
+		if arg == "" {
+			return nil, fmt.Errorf("gee: %%o requires a header name")
+		}
+		return func(sb *strings.Builder, e *accessLogEntry) {
+			sb.WriteString(headerOrDash(e.c.engine.Redaction().RedactHeader(arg, e.c.Writer.Header().Get(arg))))
+		}, nil
+	}
+	return nil, fmt.Errorf("gee: unknown access log directive %%%c", directive)
+}
+
+func headerOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return escapeLogValue(value)
+}
+
+//escapeLogValue 和Apache一样转义引号、反斜杠和不可见字符 防止伪造日志行
+func escapeLogValue(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch {
+		case b == '"' || b == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(b)
+		case b < 0x20 || b == 0x7f:
+			fmt.Fprintf(&sb, "\\x%02x", b)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String()
+}
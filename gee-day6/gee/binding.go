@@ -0,0 +1,96 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+//ErrBodyTooLarge 请求体超过engine.MaxBodyBytes
+var ErrBodyTooLarge = errors.New("gee: request body too large")
+
+//BodyBinding 定义如何把请求体解码到对象中 用户也可以实现自己的格式
+type BodyBinding interface {
+	Name() string
+	BindBody(body []byte, obj interface{}) error
+}
+
+//内置的BodyBinding 例如：c.ShouldBindBodyWith(&obj, gee.BindingJSON)
+var (
+	BindingJSON BodyBinding = jsonBinding{}
+	BindingXML  BodyBinding = xmlBinding{}
+)
+
+type jsonBinding struct{}
+
+func (jsonBinding) Name() string {
+	return "json"
+}
+
+func (jsonBinding) BindBody(body []byte, obj interface{}) error {
+	return json.Unmarshal(body, obj)
+}
+
+type xmlBinding struct{}
+
+func (xmlBinding) Name() string {
+	return "xml"
+}
+
+func (xmlBinding) BindBody(body []byte, obj interface{}) error {
+	return xml.Unmarshal(body, obj)
+}
+
+//GetRawData 用于读取整个请求体 读取后会缓存在Context中
+//每次调用都会把c.Req.Body重置为缓存的内容 所以之后的中间件和处理函数仍然可以读取请求体
+func (c *Context) GetRawData() ([]byte, error) {
+	//读取失败后请求体已经不完整了 之后的调用都返回同样的错误
+	if c.rawDataErr != nil {
+		return nil, c.rawDataErr
+	}
+	if c.rawData == nil {
+		data, err := c.readBody()
+		if err != nil {
+			c.rawDataErr = err
+			return nil, err
+		}
+		c.rawData = data
+	}
+	c.Req.Body = io.NopCloser(bytes.NewReader(c.rawData))
+	return c.rawData, nil
+}
+
+//readBody 最多读取engine.MaxBodyBytes个字节 小于等于0时不限制
+func (c *Context) readBody() ([]byte, error) {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return []byte{}, nil
+	}
+	max := c.engine.MaxBodyBytes
+	if max <= 0 {
+		return io.ReadAll(c.Req.Body)
+	}
+	data, err := io.ReadAll(io.LimitReader(c.Req.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, ErrBodyTooLarge
+	}
+	return data, nil
+}
+
+//ShouldBindBodyWith 用于把请求体解码到obj中 请求体会被缓存 可以多次使用不同的格式解码
+func (c *Context) ShouldBindBodyWith(obj interface{}, bb BodyBinding) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	if err = bb.BindBody(body, obj); err != nil {
+		return fmt.Errorf("gee: bind %s body: %w", bb.Name(), err)
+	}
+	return nil
+}
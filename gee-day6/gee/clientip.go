@@ -0,0 +1,151 @@
+package gee
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+//默认从这些请求头中读取客户端IP 只有直接连接的对端是受信任的代理时才会读取
+var defaultRemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+//SetTrustedProxies 用于设置受信任的代理 支持IP和CIDR 例如：[]string{"10.0.0.0/8", "192.168.1.2"}
+//默认不信任任何代理 此时ClientIP直接返回对端地址 传nil可以恢复默认
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return &net.ParseError{Type: "IP address", Text: proxy}
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
+}
+
+//SetRemoteIPHeaders 用于设置从哪些请求头中读取客户端IP 按顺序尝试
+//支持 X-Forwarded-For、X-Real-IP 这类只包含IP的请求头以及 RFC 7239 的 Forwarded
+func (engine *Engine) SetRemoteIPHeaders(headers ...string) {
+	engine.remoteIPHeaders = headers
+}
+
+//isTrustedProxy 判断ip是否属于受信任的代理
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range engine.trustedCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//RemoteIP 用于获取直接连接的对端IP 不读取任何请求头
+func (c *Context) RemoteIP() string {
+	ip, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(c.Req.RemoteAddr)
+	}
+	return ip
+}
+
+//ClientIP 用于获取客户端的真实IP
+//只有对端是受信任的代理时才读取请求头 从右往左跳过受信任的代理 第一个不受信任的地址就是客户端
+//这样客户端自己伪造的 X-Forwarded-For 不会被采用
+func (c *Context) ClientIP() string {
+	remoteIP := c.RemoteIP()
+	if !c.engine.isTrustedProxy(net.ParseIP(remoteIP)) {
+		return remoteIP
+	}
+	for _, header := range c.engine.remoteIPHeaders {
+		var chain []string
+		if http.CanonicalHeaderKey(header) == "Forwarded" {
+			chain = parseForwarded(c.Req.Header.Values(header))
+		} else {
+			chain = splitIPList(c.Req.Header.Values(header))
+		}
+		if ip, ok := c.engine.clientIPFromChain(chain); ok {
+			return ip
+		}
+	}
+	return remoteIP
+}
+
+//clientIPFromChain 从右往左遍历代理链 返回第一个不受信任的IP 链中有非法地址时整个请求头都不采用
+func (engine *Engine) clientIPFromChain(chain []string) (string, bool) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil {
+			return "", false
+		}
+		if i == 0 || !engine.isTrustedProxy(ip) {
+			return ip.String(), true
+		}
+	}
+	return "", false
+}
+
+//splitIPList 拆分逗号分隔的IP列表 多个同名请求头按出现顺序拼接
+func splitIPList(values []string) []string {
+	chain := make([]string, 0)
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				chain = append(chain, item)
+			}
+		}
+	}
+	return chain
+}
+
+//parseForwarded 解析 RFC 7239 Forwarded 请求头中的 for 参数
+//例如：for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+func parseForwarded(values []string) []string {
+	chain := make([]string, 0)
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			forValue := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+					forValue = strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+			//没有 for 参数或者是 unknown、_hidden 这类混淆值时保留原值 后面会被当成非法地址
+			chain = append(chain, forwardedNode(forValue))
+		}
+	}
+	return chain
+}
+
+//forwardedNode 去掉节点中的端口和IPv6的方括号
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.IndexByte(node, ']'); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
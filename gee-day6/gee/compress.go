@@ -0,0 +1,324 @@
+package gee
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//defaultCompressMinLength 小于1KB的响应压缩后收益很小 不压缩
+const defaultCompressMinLength = 1024
+
+//defaultExcludedContentTypes 本身已经压缩过的类型 再压缩只会浪费CPU
+var defaultExcludedContentTypes = []string{
+	"image/*", "video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/zstd", "application/pdf",
+}
+
+//CompressConfig 定义Compress中间件的配置
+type CompressConfig struct {
+	// Level 压缩级别 例如 gzip.BestSpeed 为0时使用 gzip.DefaultCompression
+	Level int
+	// MinLength 响应体小于这个字节数时不压缩 为0时使用1024 小于0时总是压缩
+	MinLength int
+	// ExcludedPaths 不压缩的路径前缀 例如 "/metrics"
+	ExcludedPaths []string
+	// ExcludedContentTypes 额外不压缩的类型 例如 "application/octet-stream"、"text/*"
+	ExcludedContentTypes []string
+}
+
+//compressEncoder gzip.Writer 和 zlib.Writer 的公共方法
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+//Compress 定义使用默认配置压缩响应的中间件
+func Compress() HandlerFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+//CompressWithConfig 定义根据Accept-Encoding使用gzip或deflate压缩响应的中间件
+//状态码为206、304、204 已经设置了Content-Encoding 或者是HEAD请求时不压缩
+func CompressWithConfig(config CompressConfig) HandlerFunc {
+	level := config.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		panic("gee: invalid compression level " + strconv.Itoa(level))
+	}
+	minLength := config.MinLength
+	if minLength == 0 {
+		minLength = defaultCompressMinLength
+	}
+	excludedTypes := append(append([]string{}, defaultExcludedContentTypes...), config.ExcludedContentTypes...)
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			w, _ := gzip.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+		"deflate": {New: func() interface{} {
+			w, _ := zlib.NewWriterLevel(io.Discard, level)
+			return w
+		}},
+	}
+
+	return func(c *Context) {
+		for _, prefix := range config.ExcludedPaths {
+			if strings.HasPrefix(c.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		if c.Method == http.MethodHead || c.Req.Header.Get("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		//同一个URL是否压缩取决于Accept-Encoding 缓存需要区分
+		addVary(c.Writer.Header(), "Accept-Encoding")
+		encoding := negotiateEncoding(c.Req.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: c.Writer,
+			pool:           pools[encoding],
+			encoding:       encoding,
+			minLength:      minLength,
+			excludedTypes:  excludedTypes,
+			size:           noWritten,
+		}
+		c.Writer = cw
+		completed := false
+		defer func() {
+			c.Writer = cw.ResponseWriter
+			if !completed {
+				//处理过程中panic 丢弃还没有写出的数据 让Recovery可以返回500
+				cw.buf = nil
+				cw.decided = true
+			}
+			cw.close()
+		}()
+		c.Next()
+		completed = true
+	}
+}
+
+//negotiateEncoding 根据Accept-Encoding选择压缩方式 权重相同时优先gzip 都不接受时返回空字符串
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	q := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || v < 0 || v > 1 {
+				v = 0
+			}
+			weight = v
+		}
+		q[coding] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		weight, ok := q[coding]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = coding, weight
+		}
+	}
+	return best
+}
+
+//compressWriter 先缓冲MinLength字节 再决定是否压缩 之后的写入直接经过压缩器
+type compressWriter struct {
+	ResponseWriter
+	pool          *sync.Pool
+	encoding      string
+	minLength     int
+	excludedTypes []string
+
+	buf     []byte
+	size    int // handler写入的未压缩字节数 没有写入时为-1
+	decided bool
+	encoder compressEncoder
+}
+
+var _ ResponseWriter = &compressWriter{}
+
+//WriteHeader 开始写响应体之后不能再修改状态码
+func (w *compressWriter) WriteHeader(code int) {
+	if w.Written() && code > 0 && code != w.Status() {
+		log.Printf("[WARNING] Headers were already written. Wanted to override status code %d with %d", w.Status(), code)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.size == noWritten {
+		w.size = 0
+	}
+	w.size += len(data)
+	if !w.decided {
+		w.buf = append(w.buf, data...)
+		if w.minLength > 0 && len(w.buf) < w.minLength {
+			return len(data), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Size() int {
+	return w.size
+}
+
+func (w *compressWriter) Written() bool {
+	return w.size != noWritten || w.ResponseWriter.Written()
+}
+
+//WriteHeaderNow 立即写出响应头 此时还不知道响应体大小 不压缩
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decided = true
+		w.flushBuffer()
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+//Flush 流式响应不等MinLength 直接决定是否压缩 压缩时先刷新压缩器的缓冲
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.minLength = 0
+		if err := w.decide(); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+//decide 根据状态码和响应头决定是否压缩 然后写出缓冲的数据
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		//必须在压缩前探测类型 否则net/http会根据压缩后的数据判断
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if !w.shouldCompress(header) {
+		return w.flushBuffer()
+	}
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	//压缩后的内容与原来的不同 强ETag改为弱ETag
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	encoder := w.pool.Get().(compressEncoder)
+	encoder.Reset(w.ResponseWriter)
+	w.encoder = encoder
+	return w.flushBuffer()
+}
+
+func (w *compressWriter) shouldCompress(header http.Header) bool {
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	if header.Get("Content-Encoding") != "" || len(w.buf) < w.minLength {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return true
+	}
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, excluded := range w.excludedTypes {
+		if excluded == mediaType || (strings.HasSuffix(excluded, "/*") && strings.HasPrefix(mediaType, excluded[:len(excluded)-1])) {
+			return false
+		}
+	}
+	return true
+}
+
+//flushBuffer 把缓冲的数据写到压缩器或者底层的ResponseWriter
+func (w *compressWriter) flushBuffer() error {
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+//close 写出剩余的数据 并把压缩器放回池中
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide()
+	}
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.encoder.Reset(io.Discard)
+		w.pool.Put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+//addVary 添加Vary响应头 已经存在时不重复添加
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
@@ -1,27 +1,93 @@
 package gee
 
 import (
-	"encoding/json"
-	"fmt"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 type H map[string]interface{}
 
+//MarshalXML 让H可以用c.XML输出 encoding/xml不支持map
+//每个key输出为一个子元素 按key排序 顶层的H输出为<map>
+func (h H) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	//顶层编码时元素名默认是类型名H
+	if start.Name.Local == "H" {
+		start.Name = xml.Name{Local: "map"}
+	}
+	return h.encodeXML(e, start)
+}
+
+func (h H) encodeXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		elem := xml.StartElement{Name: xml.Name{Local: key}}
+		//嵌套的H直接使用key作为元素名
+		if child, ok := h[key].(H); ok {
+			if err := child.encodeXML(e, elem); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.EncodeElement(h[key], elem); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+//Context 实现了context.Context 可以直接传给需要context.Context的函数
+var _ context.Context = &Context{}
+
 type Context struct {
 	//origin objects
-	Writer http.ResponseWriter //响应
-	Req    *http.Request       //请求
+	Writer ResponseWriter //响应
+	Req    *http.Request  //请求
 	//request information
 	Path   string
 	Method string
 	Params map[string]string //路由参数
+	//fullPath 匹配到的路由 例如：/hello/:name
+	fullPath string
 	//response info
-	StatusCode int //响应状态码
+	StatusCode int     //响应状态码 每个处理函数返回后会与c.Writer.Status()同步 处理函数中应使用c.Writer.Status()
+	Errors     []error //处理请求过程中产生的错误 由Logger等中间件统一输出
 	//middleware
 	handlers []HandlerFunc
 	index    int
 	engine   *Engine
+	//cache
+	queryCache url.Values //URL参数缓存
+	formCache  url.Values //表单参数缓存
+	rawData    []byte     //请求体缓存 由GetRawData填充
+	rawDataErr error      //读取请求体时的错误
+	//cookie
+	sameSite http.SameSite //SetCookie使用的SameSite属性
+	//redirect
+	redirects int //内部重定向的次数 防止循环重定向
+	//slog
+	logBase *slog.Logger //StructuredLogger中间件指定的logger
+	logger  *slog.Logger //c.Logger()的缓存
+	//Keys 用于在中间件和处理函数之间传递数据 通过Set和Get访问
+	Keys map[string]interface{}
+	mu   sync.RWMutex
 }
 
 //newContext 用于创建Context
@@ -29,12 +95,16 @@ func newContext(w http.ResponseWriter, r *http.Request) *Context {
 	return &Context{
 		Path:   r.URL.Path,
 		Method: r.Method,
-		Writer: w,
+		Writer: newResponseWriter(w),
 		Req:    r,
 		index:  -1,
 	}
 }
 
+//abortIndex Abort之后index会被设置为这个值 保证后续的中间件都不会再执行
+//取MaxInt32的一半 在32位平台上Next中的c.index++也不会溢出
+const abortIndex int = math.MaxInt32 / 2
+
 //Next 用于执行下一个中间件  执行handlefunc[]中的下一个函数
 func (c *Context) Next() {
 	c.index++
@@ -43,12 +113,77 @@ func (c *Context) Next() {
 	for ; c.index < s; c.index++ {
 		//执行中间件
 		c.handlers[c.index](c)
+		//处理函数可能直接通过c.Writer写入 例如File、http.ServeContent
+		c.StatusCode = c.Writer.Status()
 	}
 }
 
-//PostForm 用于POST 请求中获取表单参数
+//PostForm 用于POST 请求中获取表单参数 只读取请求体 不包括URL中的参数
 func (c *Context) PostForm(key string) string {
-	return c.Req.FormValue(key)
+	value, _ := c.GetPostForm(key)
+	return value
+}
+
+//DefaultPostForm 用于获取表单参数 参数不存在时返回defaultValue
+func (c *Context) DefaultPostForm(key string, defaultValue string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+//GetPostForm 用于获取表单参数 第二个返回值表示参数是否存在 例如：name= 返回("", true)
+func (c *Context) GetPostForm(key string) (string, bool) {
+	if values, ok := c.GetPostFormArray(key); ok {
+		return values[0], true
+	}
+	return "", false
+}
+
+//PostFormArray 用于获取同名表单参数的所有值 例如：tag=a&tag=b
+func (c *Context) PostFormArray(key string) []string {
+	values, _ := c.GetPostFormArray(key)
+	return values
+}
+
+//GetPostFormArray 用于获取同名表单参数的所有值 第二个返回值表示参数是否存在
+func (c *Context) GetPostFormArray(key string) ([]string, bool) {
+	c.initFormCache()
+	values, ok := c.formCache[key]
+	return values, ok && len(values) > 0
+}
+
+//PostFormMap 用于获取 key[xxx] 形式的表单参数 例如：user[name]=a 返回map[name:a]
+func (c *Context) PostFormMap(key string) map[string]string {
+	dict, _ := c.GetPostFormMap(key)
+	return dict
+}
+
+//GetPostFormMap 用于获取 key[xxx] 形式的表单参数 第二个返回值表示是否至少有一个
+func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
+	c.initFormCache()
+	return bracketMap(c.formCache, key)
+}
+
+//initFormCache 按engine.MaxMultipartMemory解析表单 只解析一次
+func (c *Context) initFormCache() {
+	if c.formCache != nil {
+		return
+	}
+	if c.Req.PostForm == nil {
+		if err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			c.Error(err)
+		}
+	}
+	c.formCache = c.Req.PostForm
+	if c.formCache == nil {
+		c.formCache = url.Values{}
+	}
+}
+
+//FullPath 用于获取匹配到的路由 例如：/hello/:name 没有匹配到时为空字符串
+func (c *Context) FullPath() string {
+	return c.fullPath
 }
 
 //Param 用于获取路由中的参数
@@ -59,7 +194,72 @@ func (c *Context) Param(key string) string {
 
 //Query 用于获取请求中的参数
 func (c *Context) Query(key string) string {
-	return c.Req.URL.Query().Get(key)
+	value, _ := c.GetQuery(key)
+	return value
+}
+
+//DefaultQuery 用于获取URL参数 参数不存在时返回defaultValue 例如：c.DefaultQuery("page", "1")
+func (c *Context) DefaultQuery(key string, defaultValue string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+//GetQuery 用于获取URL参数 第二个返回值表示参数是否存在 例如：?name= 返回("", true)
+func (c *Context) GetQuery(key string) (string, bool) {
+	if values, ok := c.GetQueryArray(key); ok {
+		return values[0], true
+	}
+	return "", false
+}
+
+//QueryArray 用于获取同名URL参数的所有值 例如：?id=1&id=2
+func (c *Context) QueryArray(key string) []string {
+	values, _ := c.GetQueryArray(key)
+	return values
+}
+
+//GetQueryArray 用于获取同名URL参数的所有值 第二个返回值表示参数是否存在
+func (c *Context) GetQueryArray(key string) ([]string, bool) {
+	c.initQueryCache()
+	values, ok := c.queryCache[key]
+	return values, ok && len(values) > 0
+}
+
+//QueryMap 用于获取 key[xxx] 形式的URL参数 例如：?filter[a]=1&filter[b]=2 返回map[a:1 b:2]
+func (c *Context) QueryMap(key string) map[string]string {
+	dict, _ := c.GetQueryMap(key)
+	return dict
+}
+
+//GetQueryMap 用于获取 key[xxx] 形式的URL参数 第二个返回值表示是否至少有一个
+func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
+	c.initQueryCache()
+	return bracketMap(c.queryCache, key)
+}
+
+//initQueryCache URL参数只解析一次 之后都从缓存中读取
+func (c *Context) initQueryCache() {
+	if c.queryCache == nil {
+		c.queryCache = c.Req.URL.Query()
+	}
+}
+
+//bracketMap 从values中取出所有 key[xxx] 形式的参数 同名参数取第一个值
+func bracketMap(values url.Values, key string) (map[string]string, bool) {
+	dict := make(map[string]string)
+	exist := false
+	for k, v := range values {
+		if !strings.HasPrefix(k, key+"[") || len(v) == 0 {
+			continue
+		}
+		if end := strings.IndexByte(k[len(key)+1:], ']'); end >= 0 {
+			exist = true
+			dict[k[len(key)+1:][:end]] = v[0]
+		}
+	}
+	return dict, exist
 }
 
 //Status 设置响应状态码
@@ -73,43 +273,195 @@ func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+//Render 用于通过渲染器写入响应
+//先渲染到缓冲区 成功后才写入状态码和响应体 这样渲染失败时还能返回500
+func (c *Context) Render(code int, r Render) {
+	//1xx、204、304 不允许有响应体
+	if !bodyAllowedForStatus(code) {
+		r.WriteContentType(c.Writer)
+		c.Status(code)
+		return
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer putBuffer(buf)
+	//如果渲染失败，此时还没有写入任何响应 可以返回500错误
+	if err := r.Render(&bufferedWriter{ResponseWriter: c.Writer, buf: buf}); err != nil {
+		c.Error(err)
+		c.Fail(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.SetHeader("Content-Length", strconv.Itoa(buf.Len()))
+	c.Status(code)
+	if _, err := c.Writer.Write(buf.Bytes()); err != nil {
+		c.Error(err)
+	}
+}
+
 //String 用于设置响应字符串
 func (c *Context) String(code int, format string, value ...interface{}) {
-	c.SetHeader("Content-Type", "text/plain")
-	c.Status(code)
-	c.Writer.Write([]byte(fmt.Sprintf(format, value...)))
+	c.Render(code, String{Format: format, Data: value})
 }
 
 //JSON 用于设置响应JSON
 func (c *Context) JSON(code int, obj interface{}) {
-	c.SetHeader("Content-Type", "application/json")
-	c.Status(code)
-	encoder := json.NewEncoder(c.Writer)
-	//如果编码失败，返回500错误
-	if err := encoder.Encode(obj); err != nil {
-		http.Error(c.Writer, err.Error(), 500)
-	}
+	c.Render(code, JSON{Data: obj})
+}
+
+//IndentedJSON 用于设置带缩进的JSON响应
+func (c *Context) IndentedJSON(code int, obj interface{}) {
+	c.Render(code, IndentedJSON{Data: obj})
+}
+
+//PureJSON 用于设置不转义HTML字符的JSON响应
+func (c *Context) PureJSON(code int, obj interface{}) {
+	c.Render(code, PureJSON{Data: obj})
+}
+
+//SecureJSON 用于设置防劫持的JSON响应 前缀通过engine.SetSecureJSONPrefix设置
+func (c *Context) SecureJSON(code int, obj interface{}) {
+	c.Render(code, SecureJSON{Prefix: c.engine.secureJSONPrefix, Data: obj})
+}
+
+//JSONP 用于设置JSONP响应 回调函数名从查询参数callback中获取
+func (c *Context) JSONP(code int, obj interface{}) {
+	c.Render(code, JSONP{Callback: c.Query("callback"), Data: obj})
+}
+
+//XML 用于设置XML响应
+func (c *Context) XML(code int, obj interface{}) {
+	c.Render(code, XML{Data: obj})
+}
+
+//YAML 用于设置YAML响应
+func (c *Context) YAML(code int, obj interface{}) {
+	c.Render(code, YAML{Data: obj})
 }
 
 //Date 用于设置响应数据
 func (c *Context) Date(code int, date []byte) {
-	c.Status(code)
-	c.Writer.Write(date)
+	c.Render(code, Data{Data: date})
 }
 
 //HTML 用于设置HTML响应
 func (c *Context) HTML(code int, name string, data interface{}) {
-	//设置响应头 Content-Type
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	//如果渲染失败，返回500错误,通过engine的render进行渲染
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.Fail(http.StatusInternalServerError, err.Error())
+	//通过engine的htmlTemplates进行渲染
+	c.Render(code, HTML{Template: c.engine.htmlTemplates, Name: name, Data: data})
+}
+
+//bodyAllowedForStatus 判断状态码是否允许有响应体
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
 	}
+	return true
+}
+
+//Error 用于记录处理请求时产生的错误 不会修改响应
+func (c *Context) Error(err error) {
+	if err != nil {
+		c.Errors = append(c.Errors, err)
+	}
+}
+
+//Abort 用于阻止执行后续的中间件和处理函数 不会中断当前函数
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+//IsAborted 用于判断是否已经调用过Abort
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+//Set 用于在Context中保存数据 例如：c.Set("user", user)
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+//Get 用于获取Set保存的数据 第二个返回值表示key是否存在
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.Keys[key]
+	return
+}
+
+//MustGet 用于获取Set保存的数据 key不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
+}
+
+//Deadline 实现context.Context 返回请求的截止时间
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	return c.Req.Context().Deadline()
+}
+
+//Done 实现context.Context 请求结束或者客户端断开时关闭
+func (c *Context) Done() <-chan struct{} {
+	return c.Req.Context().Done()
+}
+
+//Err 实现context.Context 返回请求被取消的原因
+func (c *Context) Err() error {
+	return c.Req.Context().Err()
+}
+
+//Value 实现context.Context 字符串key先从Keys中查找 找不到再从请求的context中查找
+//这样可以直接把c传给数据库等接受context.Context的接口
+func (c *Context) Value(key interface{}) interface{} {
+	if keyAsString, ok := key.(string); ok {
+		if value, exists := c.Get(keyAsString); exists {
+			return value
+		}
+	}
+	return c.Req.Context().Value(key)
 }
 
 //Fail 用于设置错误响应
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
+	c.Abort()
 	c.JSON(code, H{"message": err})
 }
+
+//maxPooledBufferSize 超过这个大小的缓冲区不放回池中 避免一次大响应长期占用内存
+const maxPooledBufferSize = 64 << 10
+
+//bufferPool 渲染时使用的缓冲区池
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+}
+
+//bufferedWriter 渲染时使用 响应体写入缓冲区 响应头仍然写到真正的Writer上
+type bufferedWriter struct {
+	http.ResponseWriter
+	buf *bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+//WriteHeader 状态码由Context.Render统一写入 这里忽略
+func (w *bufferedWriter) WriteHeader(int) {}
@@ -0,0 +1,197 @@
+package gee
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	//ErrNoCookieKeys 没有通过engine.SetCookieKeys设置密钥时使用签名或加密Cookie
+	ErrNoCookieKeys = errors.New("gee: cookie keys not configured")
+	//ErrInvalidCookie Cookie 签名不正确或者无法解密 可能被篡改过
+	ErrInvalidCookie = errors.New("gee: invalid cookie")
+	//ErrCookieTooLarge 编码后的 Cookie 超过浏览器的 4096 字节限制
+	ErrCookieTooLarge = errors.New("gee: cookie value too large")
+)
+
+//maxCookieSize 浏览器能保存的单个 Cookie 的最大长度
+const maxCookieSize = 4096
+
+//cookieKey 由一个密钥派生出的签名密钥和加密器
+type cookieKey struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+//SetCookieKeys 用于设置签名和加密Cookie使用的密钥 建议每个密钥至少32字节
+//第一个密钥用于签名和加密 其余的密钥只用于验证和解密 轮换密钥时把新密钥放在最前面
+func (engine *Engine) SetCookieKeys(keys ...[]byte) {
+	cookieKeys := make([]cookieKey, 0, len(keys))
+	for _, key := range keys {
+		if len(key) == 0 {
+			panic("gee: cookie key must not be empty")
+		}
+		//同一个密钥不能同时用于签名和加密 分别派生出两个子密钥
+		block, err := aes.NewCipher(deriveKey(key, "gee-cookie-encrypt"))
+		if err != nil {
+			panic(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		cookieKeys = append(cookieKeys, cookieKey{sign: deriveKey(key, "gee-cookie-sign"), aead: aead})
+	}
+	engine.cookieKeys = cookieKeys
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+//SetSameSite 用于设置之后SetCookie使用的SameSite属性
+func (c *Context) SetSameSite(sameSite http.SameSite) {
+	c.sameSite = sameSite
+}
+
+//Cookie 用于获取Cookie的值 Cookie不存在时返回http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.QueryUnescape(cookie.Value)
+}
+
+//SetCookie 用于设置Cookie path为空时为"/" maxAge小于0表示删除Cookie
+func (c *Context) SetCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
+	c.setCookie(name, url.QueryEscape(value), maxAge, path, domain, secure, httpOnly)
+}
+
+func (c *Context) setCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) {
+	if path == "" {
+		path = "/"
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		MaxAge:   maxAge,
+		Path:     path,
+		Domain:   domain,
+		SameSite: c.sameSite,
+		Secure:   secure,
+		HttpOnly: httpOnly,
+	})
+}
+
+//SetSignedCookie 用于设置带 HMAC-SHA256 签名的Cookie 客户端可以看到值但无法篡改
+func (c *Context) SetSignedCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) error {
+	keys := c.engine.cookieKeys
+	if len(keys) == 0 {
+		return ErrNoCookieKeys
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(value))
+	encoded := payload + "." + base64.RawURLEncoding.EncodeToString(signCookie(keys[0].sign, name, payload))
+	if len(name)+len(encoded) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+	c.setCookie(name, encoded, maxAge, path, domain, secure, httpOnly)
+	return nil
+}
+
+//SignedCookie 用于获取签名Cookie的值 依次使用所有密钥验证签名
+func (c *Context) SignedCookie(name string) (string, error) {
+	keys := c.engine.cookieKeys
+	if len(keys) == 0 {
+		return "", ErrNoCookieKeys
+	}
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	payload, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, key := range keys {
+		if hmac.Equal(mac, signCookie(key.sign, name, payload)) {
+			value, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return "", ErrInvalidCookie
+			}
+			return string(value), nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
+
+//signCookie 签名时带上Cookie名 防止把一个Cookie的值复制到另一个Cookie
+func signCookie(key []byte, name string, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'='})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+//SetEncryptedCookie 用于设置 AES-GCM 加密的Cookie 客户端既看不到也无法篡改
+func (c *Context) SetEncryptedCookie(name, value string, maxAge int, path, domain string, secure, httpOnly bool) error {
+	keys := c.engine.cookieKeys
+	if len(keys) == 0 {
+		return ErrNoCookieKeys
+	}
+	aead := keys[0].aead
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	//Cookie名作为附加数据参与认证
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	encoded := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(name)+len(encoded) > maxCookieSize {
+		return ErrCookieTooLarge
+	}
+	c.setCookie(name, encoded, maxAge, path, domain, secure, httpOnly)
+	return nil
+}
+
+//EncryptedCookie 用于获取加密Cookie的值 依次使用所有密钥尝试解密
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	keys := c.engine.cookieKeys
+	if len(keys) == 0 {
+		return "", ErrNoCookieKeys
+	}
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return "", ErrInvalidCookie
+	}
+	for _, key := range keys {
+		nonceSize := key.aead.NonceSize()
+		if len(sealed) < nonceSize {
+			return "", ErrInvalidCookie
+		}
+		value, err := key.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(name))
+		if err == nil {
+			return string(value), nil
+		}
+	}
+	return "", ErrInvalidCookie
+}
@@ -0,0 +1,180 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//CORSConfig 定义CORS中间件的配置
+type CORSConfig struct {
+	// AllowOrigins 允许的来源 例如："https://app.example.com"、"https://*.example.com"、"*"
+	AllowOrigins []string
+	// AllowOriginFunc 自定义判断来源是否允许 与AllowOrigins任意一个匹配即允许
+	AllowOriginFunc func(origin string) bool
+	// AllowMethods 允许的请求方法 为空时允许 GET POST PUT PATCH DELETE HEAD
+	AllowMethods []string
+	// AllowHeaders 允许的请求头 为空时使用常见的请求头 "*" 表示允许预检请求中申请的所有请求头
+	AllowHeaders []string
+	// AllowCredentials 是否允许携带Cookie等凭证 不能与AllowOrigins中的"*"同时使用
+	// 否则任意网站都能带着用户的凭证读取响应 需要动态判断来源时使用AllowOriginFunc
+	AllowCredentials bool
+	// ExposeHeaders 允许浏览器读取的响应头
+	ExposeHeaders []string
+	// MaxAge 预检请求结果的缓存时间 小于等于0时不设置
+	MaxAge time.Duration
+}
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	defaultCORSHeaders = []string{"Origin", "Content-Type", "Content-Length", "Accept", "Authorization", "X-Requested-With", "X-Request-ID"}
+)
+
+//corsOrigins 预先解析的来源列表
+type corsOrigins struct {
+	allowAll  bool
+	exact     map[string]bool
+	wildcards [][2]string // 通配符来源拆成前缀和后缀 例如 "https://" 和 ".example.com"
+}
+
+//CORS 定义处理跨域请求的中间件 一般通过engine.Use注册
+//预检请求(带Access-Control-Request-Method的OPTIONS请求)不需要注册OPTIONS路由 中间件直接返回204
+func CORS(config CORSConfig) HandlerFunc {
+	origins := parseCORSOrigins(config.AllowOrigins)
+	if !origins.allowAll && len(origins.exact) == 0 && len(origins.wildcards) == 0 && config.AllowOriginFunc == nil {
+		panic("gee: CORS requires AllowOrigins or AllowOriginFunc")
+	}
+	if origins.allowAll && config.AllowCredentials {
+		panic("gee: CORS cannot allow credentials for origin \"*\"")
+	}
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	headers := config.AllowHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	reflectHeaders := containsFold(headers, "*")
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := ""
+	if config.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	}
+	//允许所有来源时 响应与Origin无关 直接返回*
+	wildcardOrigin := origins.allowAll
+
+	return func(c *Context) {
+		origin := c.Req.Header.Get("Origin")
+		header := c.Writer.Header()
+		if !wildcardOrigin {
+			header.Add("Vary", "Origin")
+		}
+		preflight := c.Method == http.MethodOptions && c.Req.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !origins.match(origin) && (config.AllowOriginFunc == nil || !config.AllowOriginFunc(origin)) {
+			if preflight {
+				c.Status(http.StatusForbidden)
+				c.Abort()
+				return
+			}
+			//不返回CORS响应头 由浏览器拦截
+			c.Next()
+			return
+		}
+
+		if wildcardOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		if !containsFold(methods, c.Req.Header.Get("Access-Control-Request-Method")) {
+			c.Status(http.StatusForbidden)
+			c.Abort()
+			return
+		}
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+		if reflectHeaders {
+			if requested := c.Req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+		} else {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if maxAge != "" {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.Status(http.StatusNoContent)
+		c.Abort()
+	}
+}
+
+//parseCORSOrigins 解析来源列表 通配符只能出现在域名的最前面 例如 "https://*.example.com"
+func parseCORSOrigins(list []string) corsOrigins {
+	origins := corsOrigins{exact: make(map[string]bool)}
+	for _, origin := range list {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		if origin == "*" {
+			origins.allowAll = true
+			continue
+		}
+		i := strings.Index(origin, "://*.")
+		if i < 0 {
+			if strings.Contains(origin, "*") {
+				panic(fmt.Sprintf("gee: invalid CORS origin %q", origin))
+			}
+			origins.exact[origin] = true
+			continue
+		}
+		prefix, suffix := origin[:i+len("://")], origin[i+len("://*"):]
+		if strings.Contains(suffix, "*") {
+			panic(fmt.Sprintf("gee: invalid CORS origin %q", origin))
+		}
+		origins.wildcards = append(origins.wildcards, [2]string{prefix, suffix})
+	}
+	return origins
+}
+
+//match 判断来源是否在列表中 通配符只匹配子域名 不匹配域名本身
+func (o corsOrigins) match(origin string) bool {
+	if o.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if o.exact[origin] {
+		return true
+	}
+	for _, w := range o.wildcards {
+		if len(origin) <= len(w[0])+len(w[1]) || !strings.HasPrefix(origin, w[0]) || !strings.HasSuffix(origin, w[1]) {
+			continue
+		}
+		//子域名部分不能包含路径、端口或用户信息
+		if sub := origin[len(w[0]) : len(origin)-len(w[1])]; !strings.ContainsAny(sub, "/:@") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package gee
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+//DecompressConfig 定义Decompress中间件的配置
+type DecompressConfig struct {
+	// MaxSize 解压后请求体的最大字节数 防止压缩炸弹
+	// 为0时使用engine.MaxBodyBytes engine.MaxBodyBytes也小于等于0时使用10MB
+	MaxSize int64
+}
+
+//Decompress 定义使用默认配置解压请求体的中间件
+func Decompress() HandlerFunc {
+	return DecompressWithConfig(DecompressConfig{})
+}
+
+//DecompressWithConfig 定义根据Content-Encoding解压请求体的中间件 支持gzip和deflate
+//之后的PostForm、GetRawData等读到的都是解压后的内容 不支持的编码返回415
+func DecompressWithConfig(config DecompressConfig) HandlerFunc {
+	return func(c *Context) {
+		encodings := parseContentEncoding(c.Req.Header.Get("Content-Encoding"))
+		if len(encodings) == 0 || c.Req.Body == nil || c.Req.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		for _, encoding := range encodings {
+			if encoding != "gzip" && encoding != "x-gzip" && encoding != "deflate" {
+				//RFC 7694 在415响应中告诉客户端支持哪些编码
+				c.SetHeader("Accept-Encoding", "gzip, deflate")
+				c.Fail(http.StatusUnsupportedMediaType, "unsupported content encoding: "+encoding)
+				return
+			}
+		}
+
+		maxSize := config.MaxSize
+		if maxSize <= 0 {
+			maxSize = c.engine.MaxBodyBytes
+		}
+		if maxSize <= 0 {
+			maxSize = defaultMaxBodyBytes
+		}
+		body := &decompressedBody{body: c.Req.Body, remaining: maxSize}
+		var reader io.Reader = c.Req.Body
+		//多个编码按应用的顺序列出 解压时要倒过来
+		for i := len(encodings) - 1; i >= 0; i-- {
+			decoder, err := newBodyDecoder(encodings[i], reader)
+			if err != nil {
+				c.Req.Body.Close()
+				c.Fail(http.StatusBadRequest, "invalid "+encodings[i]+" request body")
+				return
+			}
+			body.decoders = append(body.decoders, decoder)
+			reader = decoder
+		}
+		body.reader = reader
+
+		c.Req.Body = body
+		c.Req.ContentLength = -1
+		c.Req.Header.Del("Content-Encoding")
+		c.Req.Header.Del("Content-Length")
+		c.Next()
+	}
+}
+
+//parseContentEncoding 解析Content-Encoding 忽略identity
+func parseContentEncoding(header string) []string {
+	encodings := make([]string, 0)
+	for _, encoding := range strings.Split(header, ",") {
+		encoding = strings.ToLower(strings.TrimSpace(encoding))
+		if encoding != "" && encoding != "identity" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return encodings
+}
+
+//newBodyDecoder 创建解压器 deflate可能是zlib格式 也可能是没有头的原始deflate数据 根据前两个字节判断
+func newBodyDecoder(encoding string, r io.Reader) (io.ReadCloser, error) {
+	if encoding == "gzip" || encoding == "x-gzip" {
+		return gzip.NewReader(r)
+	}
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+//decompressedBody 解压后的请求体 超过限制时返回ErrBodyTooLarge
+type decompressedBody struct {
+	body      io.ReadCloser
+	decoders  []io.ReadCloser
+	reader    io.Reader
+	remaining int64
+}
+
+func (b *decompressedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		//已经读到上限 再确认一下后面是否还有数据
+		var probe [1]byte
+		_, err := io.ReadFull(b.reader, probe[:])
+		switch err {
+		case nil:
+			return 0, ErrBodyTooLarge
+		case io.ErrUnexpectedEOF:
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *decompressedBody) Close() error {
+	for _, decoder := range b.decoders {
+		decoder.Close()
+	}
+	return b.body.Close()
+}
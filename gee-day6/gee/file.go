@@ -0,0 +1,176 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//File 用于返回本地文件 支持 Range、If-Modified-Since 和 If-None-Match
+func (c *Context) File(filepath string) {
+	f, err := os.Open(filepath)
+	c.serveFile(f, err)
+}
+
+//FileFromFS 用于从 http.FileSystem 中返回文件 例如：c.FileFromFS("index.html", http.Dir("./static"))
+func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
+	f, err := fs.Open(filepath)
+	c.serveFile(f, err)
+}
+
+//FileAttachment 用于以附件形式返回文件 浏览器会以 filename 为文件名下载
+func (c *Context) FileAttachment(filepath string, filename string) {
+	c.SetHeader("Content-Disposition", contentDisposition("attachment", filename))
+	c.File(filepath)
+}
+
+//serveFile 通过 http.ServeContent 返回文件 并根据修改时间和大小生成 ETag
+func (c *Context) serveFile(f http.File, err error) {
+	if err != nil {
+		c.failFile(err)
+		return
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		c.failFile(err)
+		return
+	}
+	if stat.IsDir() {
+		c.failFile(fs.ErrNotExist)
+		return
+	}
+	if c.Writer.Header().Get("ETag") == "" {
+		c.SetHeader("ETag", fmt.Sprintf(`W/"%x-%x"`, stat.Size(), stat.ModTime().UnixNano()))
+	}
+	http.ServeContent(c.Writer, c.Req, stat.Name(), stat.ModTime(), f)
+}
+
+//failFile 把打开文件的错误转换成对应的状态码 不把路径等细节返回给客户端
+func (c *Context) failFile(err error) {
+	c.Error(err)
+	//错误信息不能以附件的形式下载
+	c.Writer.Header().Del("Content-Disposition")
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		c.Fail(http.StatusNotFound, "file not found")
+	case errors.Is(err, fs.ErrPermission):
+		c.Fail(http.StatusForbidden, "forbidden")
+	default:
+		c.Fail(http.StatusInternalServerError, "failed to open file")
+	}
+}
+
+//DataFromReader 用于从 io.Reader 中返回数据 extraHeaders 会被写入响应头
+//code 为200且 reader 实现了 io.ReadSeeker 时 支持 Range 和条件请求
+//否则只支持根据 extraHeaders 中的 ETag、Last-Modified 返回304
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	for key, value := range extraHeaders {
+		c.SetHeader(key, value)
+	}
+	if contentType != "" {
+		c.SetHeader("Content-Type", contentType)
+	}
+	var modtime time.Time
+	if lastModified := c.Writer.Header().Get("Last-Modified"); lastModified != "" {
+		modtime, _ = http.ParseTime(lastModified)
+	}
+	if seeker, ok := reader.(io.ReadSeeker); ok && code == http.StatusOK {
+		http.ServeContent(c.Writer, c.Req, "", modtime, seeker)
+		return
+	}
+	if code == http.StatusOK && c.notModified(modtime) {
+		c.Writer.Header().Del("Content-Type")
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if contentLength >= 0 {
+		c.SetHeader("Content-Length", strconv.FormatInt(contentLength, 10))
+	}
+	c.Status(code)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		c.Error(err)
+	}
+}
+
+//notModified 判断条件请求是否可以直接返回304 If-None-Match 优先于 If-Modified-Since
+func (c *Context) notModified(modtime time.Time) bool {
+	if c.Method != http.MethodGet && c.Method != http.MethodHead {
+		return false
+	}
+	if inm := c.Req.Header.Get("If-None-Match"); inm != "" {
+		etag := c.Writer.Header().Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			//If-None-Match 使用弱比较 忽略 W/ 前缀
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := c.Req.Header.Get("If-Modified-Since"); ims != "" && !modtime.IsZero() {
+		t, err := http.ParseTime(ims)
+		//HTTP 时间只精确到秒
+		return err == nil && !modtime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+//contentDisposition 按 RFC 6266 生成 Content-Disposition
+//非 ASCII 文件名同时提供 filename 的 ASCII 兜底和 filename* 的 UTF-8 编码
+func contentDisposition(dispositionType string, filename string) string {
+	fallback, ascii := asciiFilename(filename)
+	if ascii {
+		return fmt.Sprintf(`%s; filename="%s"`, dispositionType, fallback)
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, fallback, encodeRFC5987(filename))
+}
+
+//asciiFilename 把不能放进 quoted-string 的字符替换成下划线
+func asciiFilename(filename string) (string, bool) {
+	ascii := true
+	var sb strings.Builder
+	for _, r := range filename {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			sb.WriteByte('_')
+			ascii = false
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), ascii
+}
+
+//encodeRFC5987 按 RFC 5987 的 attr-char 进行百分号编码
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if isAttrChar(b) {
+			sb.WriteByte(b)
+			continue
+		}
+		sb.WriteByte('%')
+		sb.WriteByte(hex[b>>4])
+		sb.WriteByte(hex[b&0x0f])
+	}
+	return sb.String()
+}
+
+func isAttrChar(b byte) bool {
+	if 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
+}
@@ -3,6 +3,8 @@ package gee
 import (
 	"html/template"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"path"
 	"strings"
@@ -26,13 +28,51 @@ type Engine struct {
 	groups        []*RouterGroup     // store all groups 存储所有的路由组
 	htmlTemplates *template.Template // for html render 用于html渲染 模板 有点类似于jsp
 	funcMap       template.FuncMap   // for html render 用于html渲染 函数映射 自定义函数 例如：{{now}}
+
+	secureJSONPrefix string // SecureJSON 的前缀 默认 while(1);
+
+	// MaxMultipartMemory 解析 multipart 表单时保存在内存中的最大字节数 超出部分写入临时文件
+	MaxMultipartMemory int64
+	// MaxBodyBytes GetRawData最多读取的请求体字节数 小于等于0时不限制
+	MaxBodyBytes int64
+
+	cookieKeys []cookieKey // 签名和加密Cookie使用的密钥 通过SetCookieKeys设置
+
+	redirectAllowList []string // Redirect允许跳转的外部域名 为nil时不做限制
+
+	trustedCIDRs    []*net.IPNet // 受信任的代理 通过SetTrustedProxies设置
+	remoteIPHeaders []string     // 从这些请求头中读取客户端IP 通过SetRemoteIPHeaders设置
+
+	webSocketConfig WebSocketConfig // c.Upgrade使用的配置 通过SetWebSocketConfig设置
+
+	slogLogger *slog.Logger // c.Logger()使用的logger 通过SetSlogLogger设置
+
+	redaction *RedactionPolicy // 日志中遮盖敏感数据的策略 通过SetRedactionPolicy设置
 }
 
+//defaultMultipartMemory 默认32MB 与net/http保持一致
+const defaultMultipartMemory = 32 << 20
+
+//defaultMaxBodyBytes GetRawData默认最多读取10MB
+const defaultMaxBodyBytes = 10 << 20
+
 //New is the Constructor of gee.engine 		定义New函数  用于创建一个engine实例
 func New() *Engine {
 	engine := &Engine{router: newRouter()}             //创建一个engine实例
 	engine.RouterGroup = &RouterGroup{engine: engine}  //初始化一个RouterGroup
 	engine.groups = []*RouterGroup{engine.RouterGroup} //初始化groups
+	engine.secureJSONPrefix = defaultSecureJSONPrefix
+	engine.MaxMultipartMemory = defaultMultipartMemory
+	engine.MaxBodyBytes = defaultMaxBodyBytes
+	engine.remoteIPHeaders = defaultRemoteIPHeaders
+	engine.SetRedactionPolicy(DefaultRedactionPolicy)
+	return engine
+}
+
+//Default 用于创建一个默认使用Logger和Recovery中间件的engine实例
+func Default() *Engine {
+	engine := New()
+	engine.Use(Logger(), Recovery())
 	return engine
 }
 
@@ -78,18 +118,24 @@ func (group *RouterGroup) Use(middleware ...HandlerFunc) {
 
 // ServeHTTP defines the interface to implement the http.Handler 定义ServeHTTP函数  实现了http.Handler接口
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := newContext(w, r)
+	c.engine = engine
+	engine.handleHTTPRequest(c)
+	//处理函数只设置了状态码而没有写响应体时 在这里把响应头写出去
+	c.Writer.WriteHeaderNow()
+}
+
+//handleHTTPRequest 根据c.Path收集中间件并交给router处理 内部重定向时也会调用
+func (engine *Engine) handleHTTPRequest(c *Context) {
 	var middlewares []HandlerFunc
 	//遍历所有的路由组
 	for _, group := range engine.groups {
-		if strings.HasPrefix(r.URL.Path, group.prefix) {
+		if strings.HasPrefix(c.Path, group.prefix) {
 			middlewares = append(middlewares, group.middleware...)
 		}
 	}
-	c := newContext(w, r)
 	c.handlers = middlewares
-	c.engine = engine
 	engine.router.handle(c)
-
 }
 
 //createStaticHandler  定义静态文件处理函数
@@ -126,3 +172,8 @@ func (engine *Engine) LoadHTMLGlob(pattern string) {
 	//这里是加载模板 例如：engine.LoadHTMLGlob("templates/*") 会加载templates目录下的所有模板 例如：templates/index.html
 	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
 }
+
+//SetSecureJSONPrefix 用于设置SecureJSON的前缀 例如：engine.SetSecureJSONPrefix(")]}',\n")
+func (engine *Engine) SetSecureJSONPrefix(prefix string) {
+	engine.secureJSONPrefix = prefix
+}
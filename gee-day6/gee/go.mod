@@ -1,4 +1,4 @@
 module gee
 
-go 1.18
+go 1.21
 
@@ -0,0 +1,125 @@
+package gee
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//可以参与内容协商的 MIME 类型
+const (
+	MIMEJSON  = "application/json"
+	MIMEHTML  = "text/html"
+	MIMEXML   = "application/xml"
+	MIMEXML2  = "text/xml"
+	MIMEPlain = "text/plain"
+)
+
+//Negotiate 定义内容协商时每种格式对应的数据 Data 作为各格式缺省时的数据
+type Negotiate struct {
+	Offered  []string    //服务端能提供的格式 例如：[]string{gee.MIMEJSON, gee.MIMEHTML}
+	HTMLName string      //HTML 使用的模板名 通过engine.htmlTemplates渲染
+	HTMLData interface{} //HTML 模板数据
+	JSONData interface{}
+	XMLData  interface{}
+	Data     interface{}
+}
+
+//acceptRange Accept 头中的一项 例如：text/html;q=0.8
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+//parseAccept 解析 Accept 头 忽略格式错误的项
+func parseAccept(header string) []acceptRange {
+	ranges := make([]acceptRange, 0)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+		r := acceptRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			r.q = q
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+//quality 返回 offer 在 Accept 中的权重 以最精确匹配的那一项为准
+func quality(ranges []acceptRange, offer string) float64 {
+	typ, subtype, _ := strings.Cut(strings.ToLower(offer), "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+//NegotiateFormat 根据 Accept 头从 offered 中选出客户端最想要的格式
+//权重相同时按 offered 的顺序 没有可接受的格式时返回空字符串
+func (c *Context) NegotiateFormat(offered ...string) string {
+	if len(offered) == 0 {
+		panic("gee: you must provide at least one offer")
+	}
+	header := c.Req.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offered[0]
+	}
+	ranges := parseAccept(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+//Negotiate 根据 Accept 头选择 JSON、XML、HTML 或纯文本进行响应 都不匹配时返回406
+func (c *Context) Negotiate(code int, config Negotiate) {
+	switch c.NegotiateFormat(config.Offered...) {
+	case MIMEJSON:
+		c.JSON(code, chooseData(config.JSONData, config.Data))
+	case MIMEHTML:
+		c.HTML(code, config.HTMLName, chooseData(config.HTMLData, config.Data))
+	case MIMEXML, MIMEXML2:
+		c.XML(code, chooseData(config.XMLData, config.Data))
+	case MIMEPlain:
+		c.String(code, "%v", config.Data)
+	default:
+		c.Fail(http.StatusNotAcceptable, "the accepted formats are not offered by the server")
+	}
+}
+
+//chooseData 优先使用格式专属的数据
+func chooseData(custom, wildcard interface{}) interface{} {
+	if custom != nil {
+		return custom
+	}
+	return wildcard
+}
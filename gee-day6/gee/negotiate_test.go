@@ -0,0 +1,44 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	engine := New()
+	engine.GET("/", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{MIMEJSON, MIMEXML},
+			Data:    H{"name": "gee", "tags": H{"lang": "go"}},
+		})
+	})
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusOK, "application/json", `{"name":"gee","tags":{"lang":"go"}}`},
+		{"*/*;q=0.1, application/xml;q=0.5", http.StatusOK, "application/xml", "<map><name>gee</name><tags><lang>go</lang></tags></map>"},
+		{"text/xml", http.StatusNotAcceptable, "application/json", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("Accept %q: status = %d, want %d body %s", tt.accept, w.Code, tt.status, w.Body)
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+			t.Errorf("Accept %q: Content-Type = %q, want %s", tt.accept, got, tt.contentType)
+		}
+		if tt.body != "" && strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("Accept %q: body = %s, want %s", tt.accept, w.Body, tt.body)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package gee
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//RateLimitAlgorithm 定义限流算法
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket 令牌桶 每个Window补充Limit个令牌 最多积攒Burst个 允许短时间的突发请求
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow 滑动窗口 按上一个窗口的计数加权估算最近一个Window内的请求数
+	SlidingWindow
+)
+
+//RateLimitPolicy 定义一个限流规则
+type RateLimitPolicy struct {
+	// Algorithm 限流算法 默认TokenBucket
+	Algorithm RateLimitAlgorithm
+	// Limit 每个Window允许的请求数
+	Limit int
+	// Window 时间窗口 例如 time.Minute
+	Window time.Duration
+	// Burst 令牌桶的容量 为0时等于Limit 只对TokenBucket有效
+	Burst int
+}
+
+//RateLimitResult 一次限流判断的结果
+type RateLimitResult struct {
+	// Allowed 是否允许这次请求
+	Allowed bool
+	// Limit 配额上限
+	Limit int
+	// Remaining 剩余的配额
+	Remaining int
+	// Reset 配额完全恢复还需要的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时 下一次请求可能被允许还需要等待的时间
+	RetryAfter time.Duration
+}
+
+//Store 保存限流状态 可以实现为Redis等外部存储 让多个实例共享配额
+//Take必须是原子的：判断并消耗一次配额 不支持的算法应该返回错误
+type Store interface {
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+//RateLimitConfig 定义RateLimit中间件的配置
+type RateLimitConfig struct {
+	RateLimitPolicy
+	// Store 限流状态的存储 为nil时使用NewMemoryStore()
+	Store Store
+	// KeyFunc 区分客户端的key 为nil时使用RateLimitByClientIP 返回空字符串时不限流
+	KeyFunc func(c *Context) string
+	// Response 超过限制时的响应 为nil时返回429 {"message":"too many requests"}
+	Response HandlerFunc
+}
+
+//RateLimitByClientIP 按客户端IP限流 见Context.ClientIP
+func RateLimitByClientIP(c *Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+//RateLimitByHeader 按请求头限流 例如API Key
+//请求头由客户端任意填写 每换一个值就是一份新的配额 MemoryStore中的状态也会无限增长
+//所以这个请求头必须是服务端签发的凭证 valid用于校验它 例如查询API Key是否存在
+//请求中没有这个头或者校验不通过时按客户端IP限流 之后的认证中间件仍然需要拒绝无效的凭证
+func RateLimitByHeader(name string, valid func(value string) bool) func(c *Context) string {
+	if valid == nil {
+		panic("gee: RateLimitByHeader requires a validation function")
+	}
+	return func(c *Context) string {
+		if value := c.Req.Header.Get(name); value != "" && valid(value) {
+			return "header:" + name + ":" + value
+		}
+		return RateLimitByClientIP(c)
+	}
+}
+
+//RateLimit 定义限流中间件 例如：r.Use(gee.RateLimit(gee.RateLimitConfig{RateLimitPolicy: gee.RateLimitPolicy{Limit: 100, Window: time.Minute}}))
+//响应中带有 RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset 超过限制时返回429和Retry-After
+//Store出错时不限流 错误通过c.Error记录
+func RateLimit(config RateLimitConfig) HandlerFunc {
+	policy := config.RateLimitPolicy
+	if policy.Limit <= 0 || policy.Window <= 0 {
+		panic("gee: rate limit requires Limit and Window greater than 0")
+	}
+	if policy.Algorithm != TokenBucket && policy.Algorithm != SlidingWindow {
+		panic(fmt.Sprintf("gee: unknown rate limit algorithm %d", policy.Algorithm))
+	}
+	if policy.Burst <= 0 {
+		policy.Burst = policy.Limit
+	}
+	store := config.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = RateLimitByClientIP
+	}
+	response := config.Response
+	if response == nil {
+		response = func(c *Context) {
+			c.Fail(http.StatusTooManyRequests, "too many requests")
+		}
+	}
+	policyHeader := rateLimitPolicyHeader(policy)
+
+	return func(c *Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		result, err := store.Take(c.Req.Context(), key, policy)
+		if err != nil {
+			c.Error(fmt.Errorf("gee: rate limit store: %w", err))
+			c.Next()
+			return
+		}
+		header := c.Writer.Header()
+		header.Set("RateLimit-Policy", policyHeader)
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.Reset), 10))
+		if !result.Allowed {
+			header.Set("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+			c.Abort()
+			response(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+//rateLimitPolicyHeader 返回RateLimit-Policy的值 配额与RateLimit-Limit一致
+//令牌桶的配额是桶的容量Burst 窗口是空桶补满需要的时间
+func rateLimitPolicyHeader(policy RateLimitPolicy) string {
+	quota, window := policy.Limit, policy.Window
+	if policy.Algorithm == TokenBucket {
+		quota = policy.Burst
+		window = time.Duration(float64(policy.Window) * float64(policy.Burst) / float64(policy.Limit))
+	}
+	return fmt.Sprintf("%d;w=%d", quota, ceilSeconds(window))
+}
+
+//ceilSeconds 把时间向上取整为秒 响应头中只能使用整数秒
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
+
+//memoryStoreShards 分片数 减少锁竞争
+const memoryStoreShards = 32
+
+//memoryStoreSweepInterval 每个分片清理过期状态的间隔
+const memoryStoreSweepInterval = time.Minute
+
+//MemoryStore 基于内存的Store 按key分片加锁 只适用于单个实例
+//过期的状态在之后的访问中顺便清理 不需要后台goroutine
+type MemoryStore struct {
+	shards [memoryStoreShards]memoryShard
+}
+
+type memoryShard struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimitEntry
+	lastSweep time.Time
+}
+
+//rateLimitEntry 一个key的限流状态 两种算法使用不同的字段
+type rateLimitEntry struct {
+	expires time.Time
+	//TokenBucket
+	tokens float64
+	last   time.Time
+	//SlidingWindow
+	windowStart time.Time
+	prevCount   int
+	currCount   int
+}
+
+var _ Store = &MemoryStore{}
+
+//NewMemoryStore 用于创建MemoryStore
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*rateLimitEntry)
+	}
+	return s
+}
+
+//Take 实现Store
+func (s *MemoryStore) Take(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	shard := &s.shards[h.Sum32()%memoryStoreShards]
+	now := time.Now()
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if now.Sub(shard.lastSweep) >= memoryStoreSweepInterval {
+		for k, entry := range shard.entries {
+			if now.After(entry.expires) {
+				delete(shard.entries, k)
+			}
+		}
+		shard.lastSweep = now
+	}
+	entry, ok := shard.entries[key]
+	if !ok || now.After(entry.expires) {
+		entry = &rateLimitEntry{}
+		shard.entries[key] = entry
+	}
+	switch policy.Algorithm {
+	case TokenBucket:
+		return entry.takeToken(now, policy), nil
+	case SlidingWindow:
+		return entry.takeWindow(now, policy), nil
+	}
+	return RateLimitResult{}, fmt.Errorf("gee: unknown rate limit algorithm %d", policy.Algorithm)
+}
+
+//takeToken 令牌桶 按经过的时间补充令牌 每次请求消耗一个
+func (e *rateLimitEntry) takeToken(now time.Time, policy RateLimitPolicy) RateLimitResult {
+	capacity := float64(policy.Burst)
+	if capacity <= 0 {
+		capacity = float64(policy.Limit)
+	}
+	//每纳秒补充的令牌数
+	rate := float64(policy.Limit) / float64(policy.Window)
+	if e.last.IsZero() {
+		e.tokens = capacity
+	} else {
+		e.tokens = math.Min(capacity, e.tokens+float64(now.Sub(e.last))*rate)
+	}
+	e.last = now
+
+	result := RateLimitResult{Limit: int(capacity)}
+	if e.tokens >= 1 {
+		e.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - e.tokens) / rate)
+	}
+	result.Remaining = int(e.tokens)
+	result.Reset = time.Duration((capacity - e.tokens) / rate)
+	//桶满之后的状态与新建的一样 可以清理
+	e.expires = now.Add(result.Reset)
+	return result
+}
+
+//takeWindow 滑动窗口 估算值 = 上一个窗口的计数 * 上一个窗口还在范围内的比例 + 当前窗口的计数
+func (e *rateLimitEntry) takeWindow(now time.Time, policy RateLimitPolicy) RateLimitResult {
+	window := policy.Window
+	start := now.Truncate(window)
+	if !start.Equal(e.windowStart) {
+		if start.Sub(e.windowStart) == window {
+			e.prevCount = e.currCount
+		} else {
+			e.prevCount = 0
+		}
+		e.currCount = 0
+		e.windowStart = start
+	}
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	estimate := float64(e.prevCount)*weight + float64(e.currCount)
+
+	result := RateLimitResult{Limit: policy.Limit}
+	if estimate+1 <= float64(policy.Limit) {
+		e.currCount++
+		estimate++
+		result.Allowed = true
+	} else if e.currCount+1 > policy.Limit || e.prevCount == 0 {
+		//当前窗口已经用完 至少要等到下一个窗口
+		result.RetryAfter = window - elapsed
+	} else {
+		//等上一个窗口的权重下降到足够低
+		need := float64(policy.Limit-1-e.currCount) / float64(e.prevCount)
+		result.RetryAfter = time.Duration((1-need)*float64(window)) - elapsed
+	}
+	result.Remaining = policy.Limit - int(math.Ceil(estimate))
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	switch {
+	case e.currCount > 0:
+		//当前窗口的请求要到下一个窗口结束时才完全不计入
+		result.Reset = 2*window - elapsed
+	case e.prevCount > 0:
+		result.Reset = window - elapsed
+	}
+	e.expires = start.Add(2 * window)
+	return result
+}
@@ -0,0 +1,98 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+//RecoveryFunc 定义panic之后的处理函数 err是recover()的返回值
+type RecoveryFunc func(c *Context, err interface{})
+
+//Recovery 定义Recovery中间件 捕获处理函数中的panic 记录遮盖后的请求和堆栈并返回500
+func Recovery() HandlerFunc {
+	return RecoveryWithHandler(defaultRecoveryHandler)
+}
+
+//RecoveryWithHandler 定义使用自定义处理函数的Recovery中间件 调用handle之前已经中止了后续处理
+func RecoveryWithHandler(handle RecoveryFunc) HandlerFunc {
+	return func(c *Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			//http.ErrAbortHandler 是net/http约定的中断方式 交给net/http处理
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			c.Error(fmt.Errorf("panic recovered: %v", err))
+			//客户端已经断开 无法再写入响应 也不需要打印堆栈
+			if isBrokenPipe(err) {
+				log.Printf("%s: %v", c.engine.Redaction().RedactURI(c.Req.RequestURI), err)
+				c.Abort()
+				return
+			}
+			log.Printf("%s\n\n%s\n\n", dumpRequest(c), trace(fmt.Sprintf("%v", err)))
+			//先中止 panic的中间件之后的处理函数都不能再执行 不依赖自定义的handle
+			c.Abort()
+			handle(c, err)
+		}()
+		c.Next()
+	}
+}
+
+//defaultRecoveryHandler 响应头还没有写出时返回500
+func defaultRecoveryHandler(c *Context, err interface{}) {
+	if c.Writer.Written() {
+		return
+	}
+	c.Fail(http.StatusInternalServerError, "Internal Server Error")
+}
+
+//isBrokenPipe 判断是否是客户端断开导致的写入错误
+func isBrokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(e, &opErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if errors.As(opErr, &syscallErr) {
+		return errors.Is(syscallErr.Err, syscall.EPIPE) || errors.Is(syscallErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
+
+//trace 获取触发panic的堆栈信息 去掉runtime内部的帧 到net/http为止
+func trace(message string) string {
+	var pcs [32]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip first 3 caller
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var str strings.Builder
+	str.WriteString(message + "\nTraceback:")
+	for {
+		frame, more := frames.Next()
+		//之后都是net/http内部的调用 对排查问题没有帮助
+		if strings.HasPrefix(frame.Function, "net/http.") {
+			break
+		}
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			str.WriteString(fmt.Sprintf("\n\t%s\n\t\t%s:%d", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return str.String()
+}
@@ -0,0 +1,307 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//MaskStrategy 定义敏感数据的遮盖方式
+type MaskStrategy int
+
+const (
+	// MaskFull 整个值替换为 [REDACTED]
+	MaskFull MaskStrategy = iota
+	// MaskPartial 只保留首尾各2个字符 例如：ab****yz 太短的值整体遮盖
+	MaskPartial
+	// MaskHash 替换为以HashKey为密钥的HMAC-SHA256前缀 可以判断两条日志中的值是否相同但看不到原值
+	// 不加密钥的哈希可以用字典还原密码、手机号这类取值范围小的值
+	MaskHash
+)
+
+const redactedText = "[REDACTED]"
+
+//maxDumpBodyBytes 调试输出中请求体的最大长度
+const maxDumpBodyBytes = 4 << 10
+
+//RedactionPolicy 定义日志和调试输出中需要遮盖的敏感数据 名字都不区分大小写
+//gee自带的Logger、StructuredLogger、AccessLog和Recovery都会使用engine上的策略
+//Recovery输出的请求内容经过RedactHeaders、RedactForm、RedactJSON处理 自定义的调试输出也应该使用它们
+type RedactionPolicy struct {
+	// Headers 请求头和响应头 例如 Authorization
+	Headers []string
+	// QueryKeys URL参数名
+	QueryKeys []string
+	// FormFields 表单字段名
+	FormFields []string
+	// JSONPaths JSON字段路径 以.分隔 * 匹配一层任意字段 ** 匹配任意多层 数组会被自动展开
+	// 例如："password"、"user.token"、"**.secret"
+	JSONPaths []string
+	// Strategy 遮盖方式
+	Strategy MaskStrategy
+	// HashKey MaskHash使用的密钥 为空时SetRedactionPolicy会为每个engine生成随机密钥
+	// 需要在多个实例的日志之间比较时设置相同的密钥 密钥不能写进日志
+	HashKey []byte
+}
+
+//DefaultRedactionPolicy engine默认使用的策略 覆盖常见的认证信息
+var DefaultRedactionPolicy = RedactionPolicy{
+	Headers:    []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+	QueryKeys:  []string{"password", "token", "access_token", "api_key", "secret"},
+	FormFields: []string{"password", "token", "secret"},
+	JSONPaths:  []string{"**.password", "**.token", "**.secret"},
+	Strategy:   MaskFull,
+}
+
+//SetRedactionPolicy 用于设置日志中遮盖敏感数据的策略 传入空的RedactionPolicy{}表示不遮盖
+func (engine *Engine) SetRedactionPolicy(policy RedactionPolicy) {
+	if len(policy.HashKey) == 0 {
+		policy.HashKey = make([]byte, 32)
+		if _, err := rand.Read(policy.HashKey); err != nil {
+			panic("gee: failed to generate redaction hash key: " + err.Error())
+		}
+	}
+	engine.redaction = &policy
+}
+
+//Redaction 用于获取engine上的遮盖策略 自定义的日志中间件也应该使用它
+func (engine *Engine) Redaction() *RedactionPolicy {
+	return engine.redaction
+}
+
+//Mask 按Strategy遮盖一个值 MaskHash没有HashKey时整体遮盖
+func (p *RedactionPolicy) Mask(value string) string {
+	switch p.Strategy {
+	case MaskPartial:
+		//按字符而不是字节截取 避免截断多字节的UTF-8字符
+		runes := []rune(value)
+		if len(runes) <= 8 {
+			return redactedText
+		}
+		return string(runes[:2]) + strings.Repeat("*", 4) + string(runes[len(runes)-2:])
+	case MaskHash:
+		if len(p.HashKey) == 0 {
+			return redactedText
+		}
+		mac := hmac.New(sha256.New, p.HashKey)
+		mac.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:6])
+	default:
+		return redactedText
+	}
+}
+
+//RedactHeader 如果name是敏感的请求头 返回遮盖后的值
+func (p *RedactionPolicy) RedactHeader(name string, value string) string {
+	if value != "" && containsFold(p.Headers, name) {
+		return p.Mask(value)
+	}
+	return value
+}
+
+//RedactHeaders 返回遮盖后的请求头副本 不修改原来的请求头
+func (p *RedactionPolicy) RedactHeaders(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for name, values := range header {
+		copied := make([]string, len(values))
+		for i, value := range values {
+			copied[i] = p.RedactHeader(name, value)
+		}
+		redacted[name] = copied
+	}
+	return redacted
+}
+
+//RedactQuery 遮盖URL参数中的敏感值 保留参数原来的顺序和编码
+func (p *RedactionPolicy) RedactQuery(rawQuery string) string {
+	if rawQuery == "" || len(p.QueryKeys) == 0 {
+		return rawQuery
+	}
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		key, _, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if containsFold(p.QueryKeys, name) {
+			value, _ := url.QueryUnescape(part[len(key)+1:])
+			parts[i] = key + "=" + url.QueryEscape(p.Mask(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+//RedactURI 遮盖请求URI中的敏感参数 例如：/login?token=abc 变成 /login?token=%5BREDACTED%5D
+func (p *RedactionPolicy) RedactURI(uri string) string {
+	path, rawQuery, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	return path + "?" + p.RedactQuery(rawQuery)
+}
+
+//RedactForm 返回遮盖后的表单副本
+func (p *RedactionPolicy) RedactForm(form url.Values) url.Values {
+	redacted := make(url.Values, len(form))
+	for key, values := range form {
+		copied := make([]string, len(values))
+		for i, value := range values {
+			if containsFold(p.FormFields, key) {
+				value = p.Mask(value)
+			}
+			copied[i] = value
+		}
+		redacted[key] = copied
+	}
+	return redacted
+}
+
+//RedactJSON 遮盖JSON中匹配JSONPaths的字段 只遮盖字符串、数字和布尔值
+func (p *RedactionPolicy) RedactJSON(body []byte) ([]byte, error) {
+	if len(p.JSONPaths) == 0 {
+		return body, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	paths := make([][]string, 0, len(p.JSONPaths))
+	for _, path := range p.JSONPaths {
+		paths = append(paths, strings.Split(path, "."))
+	}
+	doc = p.redactJSONValue(doc, nil, paths)
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+//redactJSONValue 递归遍历JSON current是当前值的路径 数组不占路径
+func (p *RedactionPolicy) redactJSONValue(v interface{}, current []string, paths [][]string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			value[key] = p.redactJSONValue(child, append(current[:len(current):len(current)], key), paths)
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = p.redactJSONValue(child, current, paths)
+		}
+		return value
+	}
+	if len(current) == 0 {
+		return v
+	}
+	for _, path := range paths {
+		if matchJSONPath(path, current) {
+			switch value := v.(type) {
+			case string:
+				return p.Mask(value)
+			case json.Number:
+				return p.Mask(value.String())
+			case bool:
+				return redactedText
+			}
+		}
+	}
+	return v
+}
+
+//matchJSONPath 判断路径是否匹配 字段名不区分大小写
+func matchJSONPath(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	switch pattern[0] {
+	case "**":
+		for i := 0; i <= len(path); i++ {
+			if matchJSONPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(path) > 0 && matchJSONPath(pattern[1:], path[1:])
+	}
+	return len(path) > 0 && strings.EqualFold(pattern[0], path[0]) && matchJSONPath(pattern[1:], path[1:])
+}
+
+//dumpRequest 返回遮盖后的请求内容 用于panic时排查问题
+//只输出处理函数已经读取过的表单和请求体 不会为了输出再去读取请求体
+func dumpRequest(c *Context) string {
+	p := c.engine.Redaction()
+	var sb strings.Builder
+	sb.WriteString(c.Req.Method + " " + escapeLogValue(p.RedactURI(c.Req.RequestURI)) + " " + c.Req.Proto)
+	header := p.RedactHeaders(c.Req.Header)
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			sb.WriteString("\n" + escapeLogValue(name) + ": " + escapeLogValue(value))
+		}
+	}
+	switch {
+	case len(c.Req.PostForm) > 0:
+		sb.WriteString("\n\n" + truncateDump(p.RedactForm(c.Req.PostForm).Encode()))
+	case len(c.rawData) > 0:
+		sb.WriteString("\n\n" + dumpBody(p, c.Req.Header.Get("Content-Type"), c.rawData))
+	}
+	return sb.String()
+}
+
+//dumpBody 只输出能够遮盖的JSON和表单请求体 其他格式只输出长度
+func dumpBody(p *RedactionPolicy, contentType string, body []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err == nil {
+			return truncateDump(p.RedactForm(form).Encode())
+		}
+	case mediaType == MIMEJSON || strings.HasSuffix(mediaType, "+json"):
+		//编码后的JSON不会包含控制字符 不需要再转义
+		redacted, err := p.RedactJSON(body)
+		if err == nil {
+			return truncateDump(string(redacted))
+		}
+	}
+	return "[" + strconv.Itoa(len(body)) + " bytes omitted]"
+}
+
+func truncateDump(s string) string {
+	if len(s) <= maxDumpBodyBytes {
+		return s
+	}
+	return s[:maxDumpBodyBytes] + "...[truncated]"
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//maxInternalRedirects 一个请求最多允许的内部重定向次数
+const maxInternalRedirects = 10
+
+//SetRedirectAllowList 用于限制Redirect能跳转到的外部域名 防止开放重定向
+//设置之后 只允许跳转到相对路径以及列表中的域名 本站的域名也需要加到列表中 例如："example.com"、"*.example.com"
+func (engine *Engine) SetRedirectAllowList(hosts ...string) {
+	allowList := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		allowList = append(allowList, strings.ToLower(host))
+	}
+	engine.redirectAllowList = allowList
+}
+
+//Redirect 用于重定向 code只能是3xx或201 例如：c.Redirect(http.StatusFound, "/login")
+//设置了engine.SetRedirectAllowList时 跳转到不在列表中的域名会返回400
+func (c *Context) Redirect(code int, location string) {
+	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
+		panic(fmt.Sprintf("gee: cannot redirect with status code %d", code))
+	}
+	if !c.engine.redirectAllowed(location) {
+		c.Fail(http.StatusBadRequest, "redirect to untrusted host")
+		return
+	}
+	c.StatusCode = code
+	http.Redirect(c.Writer, c.Req, location, code)
+}
+
+//InternalRedirect 用于在服务端内部重定向 重新经过路由和中间件处理 客户端不会收到3xx
+//调用之后当前请求剩余的中间件和处理函数不会再执行
+func (c *Context) InternalRedirect(location string) {
+	c.Abort()
+	if c.redirects >= maxInternalRedirects {
+		c.Fail(http.StatusInternalServerError, "too many internal redirects")
+		return
+	}
+	u, err := c.Req.URL.Parse(location)
+	if err != nil {
+		c.Error(err)
+		c.Fail(http.StatusInternalServerError, "invalid internal redirect location")
+		return
+	}
+	req := c.Req.Clone(c.Req.Context())
+	req.URL.Path, req.URL.RawPath, req.URL.RawQuery = u.Path, u.RawPath, u.RawQuery
+	req.RequestURI = req.URL.RequestURI()
+
+	c.Req = req
+	c.Path = req.URL.Path
+	c.Params = nil
+	c.queryCache = nil
+	c.logger = nil
+	c.index = -1
+	c.redirects++
+	c.engine.handleHTTPRequest(c)
+	//新的处理链已经执行完了 原来的处理链不能再继续
+	c.Abort()
+}
+
+//redirectAllowed 判断location是否在允许跳转的范围内
+//请求的Host头由客户端控制 不能作为判断依据
+func (engine *Engine) redirectAllowed(location string) bool {
+	if engine.redirectAllowList == nil {
+		return true
+	}
+	//浏览器会把 \ 当成 / 并忽略开头的空白和控制字符 例如：/\evil.com 会被当成 //evil.com
+	location = strings.TrimLeftFunc(location, func(r rune) bool { return r <= ' ' })
+	location = strings.ReplaceAll(location, "\\", "/")
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return true
+	}
+	//javascript: 之类的协议以及 https:evil.com 这种没有 // 的写法都不允许
+	if (u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range engine.redirectAllowList {
+		if host == allowed {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
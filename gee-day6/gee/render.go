@@ -0,0 +1,241 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+)
+
+//Render 定义渲染器接口  每一种响应格式都实现这个接口 用户也可以实现自己的格式
+type Render interface {
+	// Render 把数据写入响应体
+	Render(http.ResponseWriter) error
+	// WriteContentType 只写入 Content-Type 响应头
+	WriteContentType(w http.ResponseWriter)
+}
+
+const (
+	plainContentType        = "text/plain; charset=utf-8"
+	htmlContentType         = "text/html; charset=utf-8"
+	jsonContentType         = "application/json; charset=utf-8"
+	jsonpContentType        = "application/javascript; charset=utf-8"
+	xmlContentType          = "application/xml; charset=utf-8"
+	yamlContentType         = "application/x-yaml; charset=utf-8"
+	defaultSecureJSONPrefix = "while(1);"
+)
+
+//jsonpCallbackRegexp 合法的 JSONP 回调函数名 例如：callback、jQuery123.cb
+var jsonpCallbackRegexp = regexp.MustCompile(`^[a-zA-Z_$][0-9a-zA-Z_$.]*$`)
+
+//ErrInvalidJSONPCallback 回调函数名不合法时返回 防止通过 callback 参数注入脚本
+var ErrInvalidJSONPCallback = errors.New("gee: invalid JSONP callback name")
+
+//writeContentType 设置 Content-Type 响应头
+func writeContentType(w http.ResponseWriter, value string) {
+	w.Header().Set("Content-Type", value)
+}
+
+//String 渲染纯文本
+type String struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r String) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	var err error
+	if len(r.Data) > 0 {
+		_, err = fmt.Fprintf(w, r.Format, r.Data...)
+	} else {
+		_, err = w.Write([]byte(r.Format))
+	}
+	return err
+}
+
+func (r String) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, plainContentType)
+}
+
+//JSON 渲染 JSON 会转义 HTML 字符
+type JSON struct {
+	Data interface{}
+}
+
+func (r JSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.Data)
+}
+
+func (r JSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+//IndentedJSON 渲染带缩进的 JSON 方便调试时阅读
+type IndentedJSON struct {
+	Data interface{}
+}
+
+func (r IndentedJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(r.Data)
+}
+
+func (r IndentedJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+//PureJSON 渲染 JSON 但不转义 <、>、& 等 HTML 字符
+type PureJSON struct {
+	Data interface{}
+}
+
+func (r PureJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r.Data)
+}
+
+func (r PureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+//SecureJSON 渲染 JSON 数组时加上前缀 防止 JSON 劫持 例如：while(1);[...]
+type SecureJSON struct {
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	jsonBytes, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	//只有数组才能被 <script> 标签直接执行 所以只给数组加前缀
+	if bytes.HasPrefix(jsonBytes, []byte("[")) && bytes.HasSuffix(jsonBytes, []byte("]")) {
+		if _, err = w.Write([]byte(r.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(jsonBytes)
+	return err
+}
+
+func (r SecureJSON) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContentType)
+}
+
+//JSONP 渲染 JSONP 例如：callback({"a":1});  回调为空时退化为普通 JSON
+type JSONP struct {
+	Callback string
+	Data     interface{}
+}
+
+func (r JSONP) Render(w http.ResponseWriter) error {
+	jsonBytes, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if r.Callback == "" {
+		writeContentType(w, jsonContentType)
+		_, err = w.Write(jsonBytes)
+		return err
+	}
+	if !jsonpCallbackRegexp.MatchString(r.Callback) {
+		return ErrInvalidJSONPCallback
+	}
+	r.WriteContentType(w)
+	if _, err = w.Write([]byte(r.Callback + "(")); err != nil {
+		return err
+	}
+	if _, err = w.Write(jsonBytes); err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(");"))
+	return err
+}
+
+func (r JSONP) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonpContentType)
+}
+
+//XML 渲染 XML
+type XML struct {
+	Data interface{}
+}
+
+func (r XML) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+func (r XML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, xmlContentType)
+}
+
+//YAML 渲染 YAML 使用 gee 自带的编码器 不依赖第三方库
+type YAML struct {
+	Data interface{}
+}
+
+func (r YAML) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	yamlBytes, err := marshalYAML(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(yamlBytes)
+	return err
+}
+
+func (r YAML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, yamlContentType)
+}
+
+//HTML 通过 html/template 渲染模板
+type HTML struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+func (r HTML) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	if r.Template == nil {
+		return errors.New("gee: html templates not loaded, call LoadHTMLGlob first")
+	}
+	if r.Name == "" {
+		return r.Template.Execute(w, r.Data)
+	}
+	return r.Template.ExecuteTemplate(w, r.Name, r.Data)
+}
+
+func (r HTML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, htmlContentType)
+}
+
+//Data 直接写入字节数据  ContentType 为空时不设置响应头
+type Data struct {
+	ContentType string
+	Data        []byte
+}
+
+func (r Data) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	_, err := w.Write(r.Data)
+	return err
+}
+
+func (r Data) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, r.ContentType)
+	}
+}
@@ -0,0 +1,132 @@
+package gee
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+//RequestIDKey RequestID中间件通过c.Set保存请求ID使用的key
+const RequestIDKey = "gee.request_id"
+
+//defaultRequestIDHeader 默认读取和返回请求ID的头
+const defaultRequestIDHeader = "X-Request-ID"
+
+//defaultRequestIDMaxLength 客户端传入的请求ID最大长度
+const defaultRequestIDMaxLength = 128
+
+//requestIDContextKey 保存在c.Req.Context()中的key 避免与其他包冲突
+type requestIDContextKey struct{}
+
+//RequestIDConfig 定义RequestID中间件的配置
+type RequestIDConfig struct {
+	// Header 读取和返回请求ID的头 为空时使用 X-Request-ID
+	Header string
+	// Generator 生成请求ID的函数 为nil时生成UUIDv7
+	Generator func() string
+	// MaxLength 接受的请求ID最大长度 为0时使用128 超过时重新生成
+	MaxLength int
+	// IgnoreIncoming 为true时总是生成新的请求ID 不信任客户端传入的值
+	IgnoreIncoming bool
+}
+
+//RequestID 定义使用默认配置的请求ID中间件
+func RequestID() HandlerFunc {
+	return RequestIDWithConfig(RequestIDConfig{})
+}
+
+//RequestIDWithConfig 定义请求ID中间件 用于关联多个服务的日志
+//请求中带有合法的请求ID时沿用 否则生成一个新的 然后保存在Context中并写入响应头
+//之后可以通过c.RequestID()获取 c.Logger()和Logger中间件会自动带上
+func RequestIDWithConfig(config RequestIDConfig) HandlerFunc {
+	header := config.Header
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	generator := config.Generator
+	if generator == nil {
+		generator = newUUIDv7
+	}
+	maxLength := config.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultRequestIDMaxLength
+	}
+
+	return func(c *Context) {
+		id := ""
+		if !config.IgnoreIncoming {
+			id = c.Req.Header.Get(header)
+		}
+		if !validRequestID(id, maxLength) {
+			id = generator()
+		}
+		c.Set(RequestIDKey, id)
+		c.Req = c.Req.WithContext(context.WithValue(c.Req.Context(), requestIDContextKey{}, id))
+		c.SetHeader(header, id)
+		//c.Logger()可能已经在之前的中间件中创建过 需要带上新的请求ID
+		c.logger = nil
+		c.Next()
+	}
+}
+
+//RequestID 用于获取RequestID中间件保存的请求ID 没有使用中间件时返回空字符串
+//调用其他服务时可以把它放到请求头中 例如：req.Header.Set("X-Request-ID", c.RequestID())
+func (c *Context) RequestID() string {
+	if id, ok := c.Get(RequestIDKey); ok {
+		if s, ok := id.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+//RequestIDFromContext 用于从context.Context中获取请求ID
+//在只能拿到c.Req.Context()的地方使用 例如数据库访问层
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+//validRequestID 只接受字母、数字和 - _ . : 防止伪造日志内容
+func validRequestID(id string, maxLength int) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		b := id[i]
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		case b == '-' || b == '_' || b == '.' || b == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//newUUIDv7 生成RFC 9562中的UUIDv7 前48位是毫秒时间戳 按字典序排序就是按时间排序
+func newUUIDv7() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[6:]); err != nil {
+		panic(err)
+	}
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(time.Now().UnixMilli()))
+	copy(uuid[:6], ts[2:])
+	uuid[6] = uuid[6]&0x0f | 0x70 // version 7
+	uuid[8] = uuid[8]&0x3f | 0x80 // variant 10
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf[:])
+}
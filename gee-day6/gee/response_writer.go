@@ -0,0 +1,120 @@
+package gee
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net"
+	"net/http"
+)
+
+const noWritten = -1
+
+//ResponseWriter 包装了 http.ResponseWriter
+//状态码会延迟到第一次写入响应体时才真正写出 在此之前设置的响应头都不会丢失
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	http.Pusher
+
+	// Status 返回响应状态码 没有设置时为200
+	Status() int
+	// Size 返回已经写入的响应体字节数 没有写入时为-1
+	Size() int
+	// Written 返回响应头是否已经写出
+	Written() bool
+	// WriteHeaderNow 立即写出响应头
+	WriteHeaderNow()
+}
+
+//responseWriter ResponseWriter 的默认实现
+type responseWriter struct {
+	http.ResponseWriter
+	size   int
+	status int
+}
+
+var _ ResponseWriter = &responseWriter{}
+
+//newResponseWriter 用于创建responseWriter
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, size: noWritten, status: http.StatusOK}
+}
+
+//WriteHeader 只记录状态码 真正写出发生在WriteHeaderNow或第一次Write时
+func (w *responseWriter) WriteHeader(code int) {
+	if code > 0 && w.status != code {
+		if w.Written() {
+			log.Printf("[WARNING] Headers were already written. Wanted to override status code %d with %d", w.status, code)
+			return
+		}
+		w.status = code
+	}
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(data []byte) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = w.ResponseWriter.Write(data)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) WriteString(s string) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = io.WriteString(w.ResponseWriter, s)
+	w.size += n
+	return
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+//Flush 实现http.Flusher 先写出响应头再刷新缓冲
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//Hijack 实现http.Hijacker 连接被接管后gee不再写入任何响应
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return hijacker.Hijack()
+}
+
+//Push 实现http.Pusher 底层连接不支持HTTP/2 push时返回http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+//Unwrap 返回原始的http.ResponseWriter 供http.ResponseController使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,286 @@
+package gee
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//backupTimeFormat 备份文件名中的时间格式 按字典序排序就是按时间排序
+const backupTimeFormat = "20060102T150405.000"
+
+//RotatingFile 按大小和时间切割的日志文件 实现了io.WriteCloser 可以并发写入
+//例如：&gee.RotatingFile{Filename: "logs/access.log", MaxSize: 100 << 20, MaxBackups: 7, Compress: true}
+//切割后的文件命名为 access-20230112T150405.000.log 开启压缩时再加上 .gz
+type RotatingFile struct {
+	// Filename 日志文件路径 目录不存在时会自动创建
+	Filename string
+	// MaxSize 文件超过这个字节数时切割 小于等于0时不按大小切割
+	MaxSize int64
+	// RotateEvery 每隔多长时间切割一次 按本地时间对齐 从每天0点开始每隔RotateEvery切割
+	// 例如time.Hour在每个整点切割 24*time.Hour在每天0点切割 小于等于0时不按时间切割
+	RotateEvery time.Duration
+	// MaxBackups 最多保留的备份文件数 小于等于0时全部保留
+	MaxBackups int
+	// Compress 是否使用gzip压缩切割后的文件
+	Compress bool
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	closed     bool //Close之后不再写入 也不会再开始切割 否则bgWg.Add可能与Close中的Wait并发
+
+	//后台压缩和清理 同一时间只有一个在执行
+	bgMu sync.Mutex
+	bgWg sync.WaitGroup
+}
+
+//Write 实现io.Writer 写入前检查是否需要切割
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.needRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+//Rotate 用于手动切割 例如收到SIGHUP时
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+//Close 关闭文件 并等待后台的压缩和清理完成 之后的Write和Rotate返回os.ErrClosed
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+	f.bgWg.Wait()
+	return err
+}
+
+func (f *RotatingFile) needRotate(n int64) bool {
+	if f.MaxSize > 0 && f.size > 0 && f.size+n > f.MaxSize {
+		return true
+	}
+	return f.RotateEvery > 0 && !time.Now().Before(f.nextRotate)
+}
+
+//open 打开或创建日志文件 追加写入
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.Filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = stat.Size()
+	if f.RotateEvery > 0 {
+		f.nextRotate = nextRotateTime(time.Now(), f.RotateEvery)
+	}
+	return nil
+}
+
+//nextRotateTime 返回now之后的第一个切割时间
+//time.Truncate以UTC的零点对齐 所以这里从本地时间当天的0点开始计算
+func nextRotateTime(now time.Time, every time.Duration) time.Time {
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	const day = 24 * time.Hour
+	//整天的间隔按日期计算 不受夏令时影响
+	if every%day == 0 {
+		return midnight.AddDate(0, 0, int(every/day))
+	}
+	next := midnight.Add((now.Sub(midnight)/every + 1) * every)
+	//不能整除一天时 每天0点重新对齐
+	if tomorrow := midnight.AddDate(0, 0, 1); next.After(tomorrow) {
+		return tomorrow
+	}
+	return next
+}
+
+//rotate 把当前文件改名为备份文件 再打开新文件 调用前必须持有mu
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	backup := f.backupName(time.Now())
+	if err := os.Rename(f.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.bgWg.Add(1)
+	go f.postRotate(backup)
+	return nil
+}
+
+//backupName 生成备份文件名 同一毫秒内多次切割时加上序号
+func (f *RotatingFile) backupName(t time.Time) string {
+	dir := filepath.Dir(f.Filename)
+	ext := filepath.Ext(f.Filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.Filename), ext)
+	name := filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, t.Format(backupTimeFormat), ext))
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s-%s.%d%s", prefix, t.Format(backupTimeFormat), i, ext))
+	}
+	return name
+}
+
+//postRotate 在后台压缩备份文件并删除多余的备份 不阻塞写日志
+func (f *RotatingFile) postRotate(backup string) {
+	defer f.bgWg.Done()
+	f.bgMu.Lock()
+	defer f.bgMu.Unlock()
+	if f.Compress {
+		if err := gzipFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "gee: compress %s: %v\n", backup, err)
+		}
+	}
+	if f.MaxBackups > 0 {
+		if err := f.removeOldBackups(); err != nil {
+			fmt.Fprintf(os.Stderr, "gee: remove old log backups: %v\n", err)
+		}
+	}
+}
+
+//removeOldBackups 只保留最新的MaxBackups个备份
+//只处理本文件生成的备份 例如 access.log 不会误删同目录下 access-error.log 的文件
+func (f *RotatingFile) removeOldBackups() error {
+	dir := filepath.Dir(f.Filename)
+	ext := filepath.Ext(f.Filename)
+	prefix := strings.TrimSuffix(filepath.Base(f.Filename), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	backups := make([]logBackup, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if backup, ok := parseBackupName(entry.Name(), prefix, ext); ok {
+			backups = append(backups, backup)
+		}
+	}
+	if len(backups) <= f.MaxBackups {
+		return nil
+	}
+	//按切割时间从新到旧排序 同一毫秒内序号大的更新
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.After(backups[j].time)
+		}
+		return backups[i].seq > backups[j].seq
+	})
+	for _, backup := range backups[f.MaxBackups:] {
+		if err := os.Remove(filepath.Join(dir, backup.name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//logBackup 一个备份文件 time和seq从文件名中解析
+type logBackup struct {
+	name string
+	time time.Time
+	seq  int
+}
+
+//parseBackupName 解析 prefix-<backupTimeFormat>[.N]ext[.gz] 形式的文件名 其他文件返回false
+func parseBackupName(name, prefix, ext string) (logBackup, bool) {
+	rest := strings.TrimSuffix(name, ".gz")
+	if !strings.HasPrefix(rest, prefix) || !strings.HasSuffix(rest, ext) {
+		return logBackup{}, false
+	}
+	rest = strings.TrimSuffix(strings.TrimPrefix(rest, prefix), ext)
+	if len(rest) < len(backupTimeFormat) {
+		return logBackup{}, false
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, rest[:len(backupTimeFormat)], time.Local)
+	if err != nil {
+		return logBackup{}, false
+	}
+	seq := 0
+	if suffix := rest[len(backupTimeFormat):]; suffix != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(suffix, "."))
+		if err != nil || !strings.HasPrefix(suffix, ".") || n <= 0 {
+			return logBackup{}, false
+		}
+		seq = n
+	}
+	return logBackup{name: name, time: t, seq: seq}, true
+}
+
+//gzipFile 把文件压缩为 name.gz 成功后删除原文件
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -81,10 +81,12 @@ func (r *router) handle(c *Context) {
 	if n != nil {
 		key := c.Method + "-" + n.pattern
 		c.Params = params
+		c.fullPath = n.pattern
 		//执行对应的handler
 		c.handlers = append(c.handlers, r.handlers[key])
 	} else {
 		//如果没有找到对应的路由，直接返回404
+		c.fullPath = ""
 		c.handlers = append(c.handlers, func(c *Context) {
 			c.String(http.StatusNotFound, "404 NOT FOUND: %s", c.Path)
 		})
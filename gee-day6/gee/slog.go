@@ -0,0 +1,79 @@
+package gee
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+//SetSlogLogger 用于设置c.Logger()的默认logger 为nil时使用slog.Default()
+//例如：engine.SetSlogLogger(slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+func (engine *Engine) SetSlogLogger(logger *slog.Logger) {
+	engine.slogLogger = logger
+}
+
+//Logger 用于获取当前请求的slog.Logger 已经带上了request_id、route、method和client_ip
+//request_id来自RequestID中间件 没有使用中间件时不带这个字段
+//处理函数中的日志和访问日志使用相同的字段 方便关联
+func (c *Context) Logger() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	base := c.logBase
+	if base == nil {
+		base = c.engine.slogLogger
+	}
+	if base == nil {
+		base = slog.Default()
+	}
+	attrs := make([]interface{}, 0, 8)
+	if requestID := c.RequestID(); requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+	attrs = append(attrs,
+		slog.String("route", c.FullPath()),
+		slog.String("method", c.Method),
+		slog.String("client_ip", c.ClientIP()),
+	)
+	c.logger = base.With(attrs...)
+	return c.logger
+}
+
+//StructuredLogger 定义基于log/slog的访问日志中间件 logger为nil时使用engine.SetSlogLogger设置的logger
+//5xx使用Error级别 4xx使用Warn级别 其余使用Info级别
+func StructuredLogger(logger *slog.Logger) HandlerFunc {
+	return func(c *Context) {
+		if logger != nil {
+			c.logBase = logger
+			c.logger = nil
+		}
+		// Start timer 开始计时
+		t := time.Now()
+		path := c.Req.URL.Path
+		raw := c.Req.URL.RawQuery
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(t)),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if raw != "" {
+			attrs = append(attrs, slog.String("query", c.engine.Redaction().RedactQuery(raw)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", joinErrors(c.Errors)))
+		}
+		c.Logger().LogAttrs(c, level, "request", attrs...)
+	}
+}
@@ -0,0 +1,132 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const sseContentType = "text/event-stream"
+
+//SSEvent 定义一条 Server-Sent Event  Data 为字符串时原样输出 否则编码为 JSON
+type SSEvent struct {
+	Event string
+	ID    string
+	Retry uint //客户端断线重连的等待时间 单位毫秒 0 表示不设置
+	Data  interface{}
+}
+
+//sseFieldReplacer event 和 id 字段中不能出现换行 否则会破坏事件的分帧
+var sseFieldReplacer = strings.NewReplacer("\n", "", "\r", "")
+
+//sseNewlineReplacer 把所有换行统一为\n 先匹配\r\n
+var sseNewlineReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func (r SSEvent) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return encodeSSE(w, r)
+}
+
+func (r SSEvent) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Content-Type", sseContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	//告诉 nginx 等代理不要缓冲响应 否则事件会积压到缓冲区满才发出
+	header.Set("X-Accel-Buffering", "no")
+}
+
+//encodeSSE 按照 text/event-stream 的格式写出事件 每个事件以空行结束
+func encodeSSE(w io.Writer, event SSEvent) error {
+	var sb strings.Builder
+	if event.ID != "" {
+		sb.WriteString("id: ")
+		sb.WriteString(sseFieldReplacer.Replace(event.ID))
+		sb.WriteString("\n")
+	}
+	if event.Event != "" {
+		sb.WriteString("event: ")
+		sb.WriteString(sseFieldReplacer.Replace(event.Event))
+		sb.WriteString("\n")
+	}
+	if event.Retry > 0 {
+		sb.WriteString("retry: ")
+		sb.WriteString(strconv.FormatUint(uint64(event.Retry), 10))
+		sb.WriteString("\n")
+	}
+	data, err := sseData(event.Data)
+	if err != nil {
+		return err
+	}
+	//多行数据需要拆成多个 data 字段 客户端会用换行把它们拼回去
+	//\r\n、\r、\n 都是事件流的换行 单独的\r不处理会被用来注入新的字段
+	data = sseNewlineReplacer.Replace(data)
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: ")
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+func sseData(data interface{}) (string, error) {
+	switch v := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonBytes), nil
+}
+
+//SSEvent 用于发送一条 Server-Sent Event 并立即刷新到客户端 例如：c.SSEvent("progress", 50)
+func (c *Context) SSEvent(name string, data interface{}) {
+	c.SSEventWith(SSEvent{Event: name, Data: data})
+}
+
+//SSEventWith 用于发送带 id、retry 的完整事件
+func (c *Context) SSEventWith(event SSEvent) {
+	//事件流不能缓冲 也不能有 Content-Length 所以直接写入 c.Writer
+	if !c.Writer.Written() {
+		event.WriteContentType(c.Writer)
+	}
+	if err := encodeSSE(c.Writer, event); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Writer.Flush()
+}
+
+//Stream 用于流式响应 每执行一次 step 就刷新一次 step 返回 false 或客户端断开时结束
+//返回值表示客户端是否中途断开
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Req.Context().Done()
+	if !c.Writer.Written() {
+		c.SetHeader("X-Accel-Buffering", "no")
+	}
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+			keepOpen := step(c.Writer)
+			c.Writer.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
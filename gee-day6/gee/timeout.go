@@ -0,0 +1,305 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+//TimeoutConfig 定义Timeout中间件的配置
+type TimeoutConfig struct {
+	// Timeout 处理请求的最长时间 必须大于0
+	Timeout time.Duration
+	// StatusCode 超时后返回的状态码 为0时返回503 也可以使用504
+	StatusCode int
+	// Response 自定义超时后的响应 为nil时返回 {"message":"request timeout"}
+	Response HandlerFunc
+}
+
+//Timeout 定义超时后返回503的中间件 例如：api.Use(gee.Timeout(3 * time.Second))
+func Timeout(timeout time.Duration) HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: timeout})
+}
+
+//TimeoutWithConfig 定义限制处理时间的中间件
+//之后的中间件和处理函数在另一个goroutine中执行 使用带有截止时间的c.Req.Context()
+//响应先写入缓冲区 按时完成时再一起写出 超时后返回超时响应 处理函数之后的写入都会被丢弃
+//处理函数应该通过c.Done()或c.Req.Context()感知超时并尽快返回
+//因为响应被缓冲 c.Writer.Flush()、SSEvent和Stream不会立即发出数据 也不能Hijack接管连接
+//所以不要在需要流式响应的路由上使用 带Upgrade请求头的请求(例如WebSocket)会直接跳过超时中间件
+//处理函数中的panic会带着原来的堆栈交给当前goroutine 超时之后才发生的panic只能记录到日志
+func TimeoutWithConfig(config TimeoutConfig) HandlerFunc {
+	if config.Timeout <= 0 {
+		panic("gee: timeout must be greater than 0")
+	}
+	status := config.StatusCode
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	response := config.Response
+	if response == nil {
+		response = func(c *Context) {
+			c.Fail(status, "request timeout")
+		}
+	}
+
+	return func(c *Context) {
+		//连接升级需要Hijack 缓冲的响应做不到
+		if c.Req.Header.Get("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Req.Context(), config.Timeout)
+		defer cancel()
+
+		tw := newTimeoutWriter(c.Writer.Header().Clone())
+		tc := c.copyForTimeout(ctx, tw)
+		done := make(chan struct{})
+		panicChan := make(chan timeoutPanic, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					tw.sendPanic(panicChan, timeoutPanic{value: p, stack: debug.Stack()})
+				}
+			}()
+			tc.Next()
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			p.repanic()
+		case <-done:
+			c.mergeTimeout(tc)
+			tw.writeTo(c.Writer)
+		case <-ctx.Done():
+			tw.timeout()
+			//标记超时之前发生的panic仍然交给Recovery处理
+			select {
+			case p := <-panicChan:
+				p.repanic()
+			default:
+			}
+			c.Abort()
+			//客户端断开时不需要再返回响应
+			if ctx.Err() != context.DeadlineExceeded {
+				c.Error(ctx.Err())
+				return
+			}
+			c.Error(fmt.Errorf("gee: handler timeout after %v", config.Timeout))
+			c.StatusCode = status
+			response(c)
+		}
+	}
+}
+
+//timeoutPanic 保存处理函数goroutine中的panic和当时的堆栈
+type timeoutPanic struct {
+	value interface{}
+	stack []byte
+}
+
+//repanic 先记录处理函数的堆栈 再在当前goroutine中重新panic 交给Recovery处理
+//重新panic之后Recovery只能看到当前goroutine的堆栈
+func (p timeoutPanic) repanic() {
+	if p.value != http.ErrAbortHandler {
+		log.Printf("gee: panic in timeout handler: %v\n%s", p.value, p.stack)
+	}
+	panic(p.value)
+}
+
+//copyForTimeout 复制一个Context给超时中间件的goroutine使用 Keys和Errors都是独立的副本
+//超时之后两个goroutine不会再访问同一份可变数据
+func (c *Context) copyForTimeout(ctx context.Context, w ResponseWriter) *Context {
+	tc := &Context{
+		Writer:     w,
+		Req:        c.Req.Clone(ctx),
+		Path:       c.Path,
+		Method:     c.Method,
+		fullPath:   c.fullPath,
+		StatusCode: c.StatusCode,
+		Errors:     append([]error(nil), c.Errors...),
+		handlers:   c.handlers,
+		index:      c.index,
+		engine:     c.engine,
+		queryCache: c.queryCache,
+		formCache:  c.formCache,
+		rawData:    c.rawData,
+		rawDataErr: c.rawDataErr,
+		sameSite:   c.sameSite,
+		redirects:  c.redirects,
+		logBase:    c.logBase,
+		logger:     c.logger,
+	}
+	if c.Params != nil {
+		tc.Params = make(map[string]string, len(c.Params))
+		for k, v := range c.Params {
+			tc.Params[k] = v
+		}
+	}
+	c.mu.RLock()
+	if c.Keys != nil {
+		tc.Keys = make(map[string]interface{}, len(c.Keys))
+		for k, v := range c.Keys {
+			tc.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return tc
+}
+
+//mergeTimeout 处理函数按时完成后 把副本中的状态合并回来
+func (c *Context) mergeTimeout(tc *Context) {
+	c.Req = tc.Req.WithContext(c.Req.Context())
+	c.Path = tc.Path
+	c.Params = tc.Params
+	c.fullPath = tc.fullPath
+	c.StatusCode = tc.StatusCode
+	c.Errors = tc.Errors
+	c.index = tc.index
+	c.queryCache = tc.queryCache
+	c.formCache = tc.formCache
+	c.rawData = tc.rawData
+	c.rawDataErr = tc.rawDataErr
+	c.sameSite = tc.sameSite
+	c.redirects = tc.redirects
+	c.logger = tc.logger
+	c.mu.Lock()
+	c.Keys = tc.Keys
+	c.mu.Unlock()
+}
+
+//timeoutWriter 缓冲整个响应 超时之后的写入返回http.ErrHandlerTimeout
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	status      int
+	size        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+var _ ResponseWriter = &timeoutWriter{}
+
+func newTimeoutWriter(header http.Header) *timeoutWriter {
+	return &timeoutWriter{header: header, status: http.StatusOK, size: noWritten}
+}
+
+//Header 超时之后返回的是副本的响应头 修改不会影响真正的响应
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if code > 0 && !w.timedOut && !w.wroteHeader && w.size == noWritten {
+		w.status = code
+	}
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.size == noWritten {
+		w.size = 0
+	}
+	n, err := w.buf.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *timeoutWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *timeoutWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.wroteHeader || w.size != noWritten
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.wroteHeader = true
+}
+
+//Flush 响应全部缓冲 流式响应在超时中间件中不生效
+func (w *timeoutWriter) Flush() {}
+
+//Hijack 缓冲的响应无法接管连接
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	return http.ErrNotSupported
+}
+
+//timeout 标记已经超时 丢弃缓冲的数据
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	w.buf.Reset()
+}
+
+//sendPanic 没有超时时把panic交给中间件所在的goroutine 超时之后没有人接收 直接记录到日志
+//与timeout使用同一把锁 保证panic要么被接收要么被记录
+func (w *timeoutWriter) sendPanic(ch chan<- timeoutPanic, p timeoutPanic) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		ch <- p
+		return
+	}
+	if p.value != http.ErrAbortHandler {
+		log.Printf("gee: panic after handler timeout: %v\n%s", p.value, p.stack)
+	}
+}
+
+//writeTo 把缓冲的响应写到真正的ResponseWriter 只在处理函数返回之后调用
+func (w *timeoutWriter) writeTo(dst ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	header := dst.Header()
+	for key := range header {
+		if _, ok := w.header[key]; !ok {
+			header.Del(key)
+		}
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+	//只设置了状态码时 与直接写入时一样延迟到最后写出
+	dst.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		dst.Write(w.buf.Bytes())
+	} else if w.wroteHeader || w.size != noWritten {
+		dst.WriteHeaderNow()
+	}
+}
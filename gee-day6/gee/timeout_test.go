@@ -0,0 +1,75 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutCompletesInTime(t *testing.T) {
+	engine := New()
+	var user interface{}
+	var status int
+	engine.Use(func(c *Context) {
+		c.Next()
+		//处理函数中的修改应该合并回当前的Context
+		user, _ = c.Get("user")
+		status = c.StatusCode
+	}, Timeout(time.Second))
+	engine.GET("/", func(c *Context) {
+		c.Set("user", "gee")
+		c.SetHeader("X-Handler", "done")
+		c.String(http.StatusCreated, "ok")
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "ok" {
+		t.Errorf("response = %d %q, want 201 ok", w.Code, w.Body)
+	}
+	if got := w.Header().Get("X-Handler"); got != "done" {
+		t.Errorf("X-Handler = %q, want done", got)
+	}
+	if user != "gee" || status != http.StatusCreated {
+		t.Errorf("merged Keys user = %v StatusCode = %d, want gee 201", user, status)
+	}
+}
+
+func TestTimeoutDiscardsLateWrites(t *testing.T) {
+	engine := New()
+	engine.Use(Timeout(20 * time.Millisecond))
+	finished := make(chan struct{})
+	engine.GET("/", func(c *Context) {
+		defer close(finished)
+		<-c.Done()
+		c.SetHeader("X-Late", "1")
+		c.String(http.StatusOK, "late")
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-finished
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "late") || w.Header().Get("X-Late") != "" {
+		t.Errorf("late write reached the client: headers %v body %q", w.Header(), w.Body)
+	}
+}
+
+func TestTimeoutPanicReachesRecovery(t *testing.T) {
+	engine := New()
+	engine.Use(Recovery(), Timeout(time.Second))
+	engine.GET("/", func(c *Context) {
+		c.SetHeader("X-Partial", "1")
+		panic("boom")
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if w.Header().Get("X-Partial") != "" {
+		t.Errorf("headers from the panicking handler reached the client: %v", w.Header())
+	}
+}
@@ -0,0 +1,96 @@
+package gee
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//ErrUnsafePath 保存上传文件时目标路径包含 .. 或者文件名不合法
+var ErrUnsafePath = errors.New("gee: unsafe upload path")
+
+//FormFile 用于获取上传的文件 例如：file, err := c.FormFile("avatar")
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if c.Req.MultipartForm == nil {
+		if err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory); err != nil {
+			return nil, err
+		}
+	}
+	f, fh, err := c.Req.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+//MultipartForm 用于获取解析后的 multipart 表单 包括所有字段和文件
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	err := c.Req.ParseMultipartForm(c.engine.MaxMultipartMemory)
+	return c.Req.MultipartForm, err
+}
+
+//SaveUploadedFile 用于把上传的文件保存到 dst
+//dst 以 / 结尾或者是已存在的目录时 文件保存到该目录下 文件名取自上传的文件名
+//dst 中不允许出现 .. 防止把文件写到目标目录之外
+func (c *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	if hasDotDot(dst) {
+		return ErrUnsafePath
+	}
+	if strings.HasSuffix(dst, "/") || strings.HasSuffix(dst, string(filepath.Separator)) || isDir(dst) {
+		name, err := sanitizeFilename(file.Filename)
+		if err != nil {
+			return err
+		}
+		dst = filepath.Join(dst, name)
+	}
+	dst = filepath.Clean(dst)
+
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, src)
+	return err
+}
+
+//sanitizeFilename 只保留客户端文件名的最后一段 去掉目录部分
+func sanitizeFilename(filename string) (string, error) {
+	//Windows 客户端可能会传 C:\\dir\\a.txt 这样的路径
+	filename = strings.ReplaceAll(filename, "\\", "/")
+	name := filename[strings.LastIndex(filename, "/")+1:]
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, 0) {
+		return "", ErrUnsafePath
+	}
+	return name, nil
+}
+
+//hasDotDot 判断路径中是否有 .. 这一段
+func hasDotDot(path string) bool {
+	path = strings.ReplaceAll(path, "\\", "/")
+	for _, part := range strings.Split(path, "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func isDir(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && stat.IsDir()
+}
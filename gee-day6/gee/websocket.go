@@ -0,0 +1,524 @@
+package gee
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//WebSocket 消息类型 与 RFC 6455 的 opcode 一致
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+//WebSocket 关闭码 见 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	//websocketGUID 握手时用于计算 Sec-WebSocket-Accept 见 RFC 6455 1.3
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	//continuationFrame 分片消息中后续帧的 opcode
+	continuationFrame = 0
+	//maxControlPayload 控制帧的最大负载
+	maxControlPayload = 125
+	//defaultWebSocketReadLimit 默认单条消息最大1MB
+	defaultWebSocketReadLimit = 1 << 20
+)
+
+var (
+	//ErrCloseSent 已经发送了关闭帧之后不能再写入消息
+	ErrCloseSent = errors.New("gee: websocket close sent")
+	//ErrReadLimit 消息超过了ReadLimit
+	ErrReadLimit = errors.New("gee: websocket read limit exceeded")
+)
+
+//CloseError 对端发送关闭帧或者协议出错时ReadMessage返回的错误
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("gee: websocket closed: %d %s", e.Code, e.Text)
+}
+
+//WebSocketConfig 定义WebSocket握手和连接的配置 通过engine.SetWebSocketConfig设置
+type WebSocketConfig struct {
+	// Subprotocols 服务端支持的子协议 按优先级排列
+	Subprotocols []string
+	// CheckOrigin 校验Origin 为nil时只允许与Host相同的Origin 防止跨站WebSocket劫持
+	CheckOrigin func(r *http.Request) bool
+	// ReadLimit 单条消息的最大字节数 小于等于0时使用默认的1MB
+	ReadLimit int64
+	// WriteFragmentSize 大于0时 WriteMessage会把消息拆分成不超过这个大小的分片
+	WriteFragmentSize int
+}
+
+//SetWebSocketConfig 用于设置c.Upgrade使用的配置
+func (engine *Engine) SetWebSocketConfig(config WebSocketConfig) {
+	engine.webSocketConfig = config
+}
+
+//Upgrade 用于把当前请求升级为WebSocket连接 例如：conn, err := c.Upgrade()
+//握手失败时已经向客户端返回了错误响应 调用者只需要返回
+func (c *Context) Upgrade() (*Conn, error) {
+	config := c.engine.webSocketConfig
+	r := c.Req
+	if r.Method != http.MethodGet {
+		return nil, c.upgradeFail(http.StatusMethodNotAllowed, "websocket: request method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return nil, c.upgradeFail(http.StatusBadRequest, "websocket: 'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, c.upgradeFail(http.StatusBadRequest, "websocket: 'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, c.upgradeFail(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, c.upgradeFail(http.StatusBadRequest, "websocket: invalid 'Sec-WebSocket-Key' header")
+	}
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, c.upgradeFail(http.StatusForbidden, "websocket: request origin not allowed")
+	}
+	if c.Writer.Written() {
+		return nil, errors.New("websocket: response already written")
+	}
+	subprotocol := selectSubprotocol(r, config.Subprotocols)
+
+	//先记录状态码 Logger可以输出101 接管之后就不能再修改了
+	c.Status(http.StatusSwitchingProtocols)
+	netConn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		//HTTP/2或者缓冲响应的Writer不支持接管连接 把101改回错误响应
+		return nil, c.upgradeFail(http.StatusInternalServerError, "websocket: "+err.Error())
+	}
+	//http.Server 设置的超时对升级后的连接不再适用
+	netConn.SetDeadline(time.Time{})
+
+	var sb strings.Builder
+	sb.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	sb.WriteString(computeAcceptKey(key))
+	sb.WriteString("\r\n")
+	if subprotocol != "" {
+		sb.WriteString("Sec-WebSocket-Protocol: ")
+		sb.WriteString(subprotocol)
+		sb.WriteString("\r\n")
+	}
+	sb.WriteString("\r\n")
+	if _, err = netConn.Write([]byte(sb.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	readLimit := config.ReadLimit
+	if readLimit <= 0 {
+		readLimit = defaultWebSocketReadLimit
+	}
+	return &Conn{
+		conn:         netConn,
+		br:           brw.Reader,
+		subprotocol:  subprotocol,
+		readLimit:    readLimit,
+		fragmentSize: config.WriteFragmentSize,
+	}, nil
+}
+
+//upgradeFail 握手失败时返回错误响应
+func (c *Context) upgradeFail(code int, reason string) error {
+	c.Fail(code, reason)
+	return errors.New(reason)
+}
+
+//computeAcceptKey 计算 Sec-WebSocket-Accept
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key))
+	h.Write([]byte(websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+//sameOrigin 没有Origin的请求(非浏览器客户端)或者Origin的host与Host相同时允许
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+//selectSubprotocol 按服务端的优先级选择客户端也支持的子协议
+func selectSubprotocol(r *http.Request, supported []string) string {
+	requested := make(map[string]bool)
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			requested[strings.TrimSpace(protocol)] = true
+		}
+	}
+	for _, protocol := range supported {
+		if requested[protocol] {
+			return protocol
+		}
+	}
+	return ""
+}
+
+//headerContainsToken 判断逗号分隔的请求头中是否包含token 不区分大小写
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Conn 服务端的WebSocket连接
+//同一时间只能有一个goroutine调用ReadMessage 写方法可以在多个goroutine中并发调用
+type Conn struct {
+	conn         net.Conn
+	br           *bufio.Reader
+	subprotocol  string
+	readLimit    int64
+	fragmentSize int
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	//PongHandler 收到pong时调用 可以用来刷新读超时
+	PongHandler func(data []byte)
+}
+
+//Subprotocol 返回协商出的子协议 没有时为空字符串
+func (conn *Conn) Subprotocol() string {
+	return conn.subprotocol
+}
+
+//SetReadLimit 用于设置单条消息的最大字节数 小于等于0时使用默认的1MB
+func (conn *Conn) SetReadLimit(limit int64) {
+	if limit <= 0 {
+		limit = defaultWebSocketReadLimit
+	}
+	conn.readLimit = limit
+}
+
+//SetReadDeadline 用于设置读超时 例如心跳检测
+func (conn *Conn) SetReadDeadline(t time.Time) error {
+	return conn.conn.SetReadDeadline(t)
+}
+
+//SetWriteDeadline 用于设置写超时
+func (conn *Conn) SetWriteDeadline(t time.Time) error {
+	return conn.conn.SetWriteDeadline(t)
+}
+
+//RemoteAddr 返回对端地址
+func (conn *Conn) RemoteAddr() net.Addr {
+	return conn.conn.RemoteAddr()
+}
+
+//Close 直接关闭底层连接 不发送关闭帧 正常关闭请先调用WriteClose
+func (conn *Conn) Close() error {
+	return conn.conn.Close()
+}
+
+//WriteMessage 用于发送一条消息 messageType可以是TextMessage、BinaryMessage、PingMessage或PongMessage
+func (conn *Conn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case TextMessage, BinaryMessage:
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return errors.New("gee: websocket text message is not valid UTF-8")
+		}
+		return conn.writeData(messageType, data)
+	case PingMessage, PongMessage:
+		return conn.writeControl(messageType, data)
+	case CloseMessage:
+		return errors.New("gee: use WriteClose to send a close message")
+	}
+	return fmt.Errorf("gee: unknown websocket message type %d", messageType)
+}
+
+//WriteClose 用于发送关闭帧 之后还应该继续ReadMessage直到收到对端的关闭帧再调用Close
+func (conn *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	return conn.writeControl(CloseMessage, payload)
+}
+
+//writeData 写入数据帧 配置了WriteFragmentSize时拆分为多个分片
+func (conn *Conn) writeData(opcode int, data []byte) error {
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	if conn.closeSent {
+		return ErrCloseSent
+	}
+	size := conn.fragmentSize
+	if size <= 0 || len(data) <= size {
+		return conn.writeFrame(true, opcode, data)
+	}
+	for len(data) > 0 {
+		n := size
+		if len(data) < n {
+			n = len(data)
+		}
+		if err := conn.writeFrame(n == len(data), opcode, data[:n]); err != nil {
+			return err
+		}
+		//第一帧之后都是continuation帧
+		opcode = continuationFrame
+		data = data[n:]
+	}
+	return nil
+}
+
+//writeControl 写入控制帧 控制帧可以插在分片消息之间
+func (conn *Conn) writeControl(opcode int, data []byte) error {
+	if len(data) > maxControlPayload {
+		return errors.New("gee: websocket control frame payload too large")
+	}
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	if conn.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		conn.closeSent = true
+	}
+	return conn.writeFrame(true, opcode, data)
+}
+
+//writeFrame 写入一帧 服务端发送的帧不需要掩码 调用前必须持有writeMu
+func (conn *Conn) writeFrame(fin bool, opcode int, data []byte) error {
+	frame := make([]byte, 10, 10+len(data))
+	frame[0] = byte(opcode)
+	if fin {
+		frame[0] |= 0x80
+	}
+	headerSize := 2
+	switch length := len(data); {
+	case length <= 125:
+		frame[1] = byte(length)
+	case length <= 0xffff:
+		frame[1] = 126
+		binary.BigEndian.PutUint16(frame[2:], uint16(length))
+		headerSize = 4
+	default:
+		frame[1] = 127
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+		headerSize = 10
+	}
+	frame = append(frame[:headerSize], data...)
+	_, err := conn.conn.Write(frame)
+	return err
+}
+
+//ReadMessage 用于读取一条完整的消息 分片会被自动拼接
+//ping会自动回复pong 收到关闭帧时回复关闭帧并返回*CloseError
+func (conn *Conn) ReadMessage() (int, []byte, error) {
+	var message []byte
+	messageType := 0
+	for {
+		fin, opcode, payload, err := conn.readFrame(int64(len(message)), messageType != 0)
+		if err != nil {
+			return 0, nil, conn.handleReadError(err)
+		}
+		switch opcode {
+		case PingMessage:
+			if err = conn.writeControl(PongMessage, payload); err != nil && err != ErrCloseSent {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if conn.PongHandler != nil {
+				conn.PongHandler(payload)
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, conn.handleClose(payload)
+		case continuationFrame:
+			message = append(message, payload...)
+		default:
+			messageType = opcode
+			message = payload
+		}
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, conn.handleReadError(&CloseError{Code: CloseInvalidFramePayloadData, Text: "invalid UTF-8 in text message"})
+		}
+		return messageType, message, nil
+	}
+}
+
+//readFrame 读取并解码一帧 received是当前分片消息已经读到的字节数 fragmented表示是否正在读取分片消息
+func (conn *Conn) readFrame(received int64, fragmented bool) (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(conn.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7f)
+
+	//没有协商扩展 RSV位必须为0
+	if head[0]&0x70 != 0 {
+		return fin, opcode, nil, protocolError("reserved bits set")
+	}
+	isControl := opcode >= CloseMessage
+	switch opcode {
+	case continuationFrame:
+		if !fragmented {
+			return fin, opcode, nil, protocolError("unexpected continuation frame")
+		}
+	case TextMessage, BinaryMessage:
+		if fragmented {
+			return fin, opcode, nil, protocolError("expected continuation frame")
+		}
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > maxControlPayload {
+			return fin, opcode, nil, protocolError("invalid control frame")
+		}
+	default:
+		return fin, opcode, nil, protocolError(fmt.Sprintf("unknown opcode %d", opcode))
+	}
+	//客户端发送的帧必须带掩码
+	if !masked {
+		return fin, opcode, nil, protocolError("client frame is not masked")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(conn.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(conn.br, ext[:]); err != nil {
+			return
+		}
+		if ext[0]&0x80 != 0 {
+			return fin, opcode, nil, protocolError("invalid payload length")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	//在读取负载之前检查大小 避免恶意的超大帧占用内存
+	if !isControl && received+length > conn.readLimit {
+		return fin, opcode, nil, ErrReadLimit
+	}
+
+	var maskKey [4]byte
+	if _, err = io.ReadFull(conn.br, maskKey[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(conn.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= maskKey[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+//protocolError 违反协议时使用1002关闭连接
+func protocolError(text string) error {
+	return &CloseError{Code: CloseProtocolError, Text: text}
+}
+
+//handleReadError 协议错误和消息过大时先发送关闭帧再关闭连接
+func (conn *Conn) handleReadError(err error) error {
+	var closeErr *CloseError
+	switch {
+	case errors.As(err, &closeErr):
+		conn.WriteClose(closeErr.Code, closeErr.Text)
+		conn.conn.Close()
+	case errors.Is(err, ErrReadLimit):
+		conn.WriteClose(CloseMessageTooBig, "message too big")
+		conn.conn.Close()
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		//对端没有发送关闭帧就断开了
+		return &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	return err
+}
+
+//handleClose 处理对端的关闭帧 回复关闭帧完成关闭握手
+func (conn *Conn) handleClose(payload []byte) error {
+	code, text := CloseNoStatusReceived, ""
+	switch {
+	case len(payload) == 1:
+		return conn.handleReadError(protocolError("invalid close payload"))
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return conn.handleReadError(protocolError("invalid close code"))
+		}
+		if !utf8.ValidString(text) {
+			return conn.handleReadError(&CloseError{Code: CloseInvalidFramePayloadData, Text: "invalid UTF-8 in close reason"})
+		}
+	}
+	replyCode := code
+	if replyCode == CloseNoStatusReceived {
+		replyCode = CloseNormalClosure
+	}
+	if err := conn.WriteClose(replyCode, ""); err != nil && err != ErrCloseSent {
+		return err
+	}
+	return &CloseError{Code: code, Text: text}
+}
+
+//validCloseCode 判断关闭码是否可以出现在关闭帧中
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
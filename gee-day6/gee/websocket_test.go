@@ -0,0 +1,269 @@
+package gee
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testWebSocketKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+//newWebSocketServer 启动一个只有 /ws 路由的测试服务器
+func newWebSocketServer(t *testing.T, config WebSocketConfig, handler func(conn *Conn)) *httptest.Server {
+	t.Helper()
+	engine := New()
+	engine.SetWebSocketConfig(config)
+	engine.GET("/ws", func(c *Context) {
+		conn, err := c.Upgrade()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	})
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return server
+}
+
+//dialWebSocket 使用原始TCP连接完成握手 返回连接和握手响应
+func dialWebSocket(t *testing.T, server *httptest.Server, headers ...string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var sb strings.Builder
+	sb.WriteString("GET /ws HTTP/1.1\r\nHost: " + server.Listener.Addr().String() + "\r\n")
+	sb.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n")
+	sb.WriteString("Sec-WebSocket-Key: " + testWebSocketKey + "\r\n")
+	for _, header := range headers {
+		sb.WriteString(header + "\r\n")
+	}
+	sb.WriteString("\r\n")
+	if _, err = conn.Write([]byte(sb.String())); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, br, resp
+}
+
+//writeClientFrame 按客户端的要求发送带掩码的帧
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode int, payload []byte) {
+	t.Helper()
+	frame := []byte{byte(opcode), 0x80}
+	if fin {
+		frame[0] |= 0x80
+	}
+	switch length := len(payload); {
+	case length <= 125:
+		frame[1] |= byte(length)
+	case length <= 0xffff:
+		frame[1] |= 126
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame[1] |= 127
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	maskKey := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, maskKey...)
+	for i, b := range payload {
+		frame = append(frame, b^maskKey[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//readServerFrame 读取服务端发送的一帧 服务端的帧不带掩码
+func readServerFrame(t *testing.T, br *bufio.Reader) (bool, int, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frame must not be masked")
+	}
+	length := int(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(br, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0]&0x80 != 0, int(head[0] & 0x0f), payload
+}
+
+func closePayload(code int, reason string) []byte {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	return append(payload, reason...)
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	server := newWebSocketServer(t, WebSocketConfig{Subprotocols: []string{"chat", "superchat"}}, func(conn *Conn) {
+		conn.ReadMessage()
+	})
+	_, _, resp := dialWebSocket(t, server, "Sec-WebSocket-Protocol: superchat, chat")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	//RFC 6455 1.3 中的示例
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != "chat" {
+		t.Errorf("Sec-WebSocket-Protocol = %q, want server preference chat", got)
+	}
+}
+
+func TestWebSocketHandshakeRejected(t *testing.T) {
+	server := newWebSocketServer(t, WebSocketConfig{}, func(conn *Conn) {})
+	tests := []struct {
+		name    string
+		headers []string
+		want    int
+	}{
+		{"cross origin", []string{"Origin: http://evil.example"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		_, _, resp := dialWebSocket(t, server, tt.headers...)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestWebSocketUpgradeWithoutHijacker(t *testing.T) {
+	engine := New()
+	errc := make(chan error, 1)
+	engine.GET("/ws", func(c *Context) {
+		_, err := c.Upgrade()
+		errc <- err
+	})
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", testWebSocketKey)
+	//httptest.ResponseRecorder 不支持Hijack
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if err := <-errc; err == nil {
+		t.Fatal("Upgrade succeeded without a Hijacker")
+	}
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+}
+
+func TestWebSocketFragmentationAndPing(t *testing.T) {
+	server := newWebSocketServer(t, WebSocketConfig{WriteFragmentSize: 2}, func(conn *Conn) {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(messageType, message)
+		conn.ReadMessage()
+	})
+	conn, br, _ := dialWebSocket(t, server)
+
+	//分片之间插入一个ping 服务端应该先回复pong
+	writeClientFrame(t, conn, false, TextMessage, []byte("hel"))
+	writeClientFrame(t, conn, true, PingMessage, []byte("p"))
+	writeClientFrame(t, conn, true, continuationFrame, []byte("lo"))
+
+	fin, opcode, payload := readServerFrame(t, br)
+	if !fin || opcode != PongMessage || string(payload) != "p" {
+		t.Fatalf("got fin=%v opcode=%d payload=%q, want pong \"p\"", fin, opcode, payload)
+	}
+	var message []byte
+	wantOpcodes := []int{TextMessage, continuationFrame, continuationFrame}
+	for i, want := range wantOpcodes {
+		fin, opcode, payload = readServerFrame(t, br)
+		if opcode != want || fin != (i == len(wantOpcodes)-1) {
+			t.Fatalf("fragment %d: fin=%v opcode=%d", i, fin, opcode)
+		}
+		message = append(message, payload...)
+	}
+	if string(message) != "hello" {
+		t.Errorf("echo = %q, want hello", message)
+	}
+}
+
+func TestWebSocketCloseHandshake(t *testing.T) {
+	errc := make(chan error, 1)
+	server := newWebSocketServer(t, WebSocketConfig{}, func(conn *Conn) {
+		_, _, err := conn.ReadMessage()
+		errc <- err
+	})
+	conn, br, _ := dialWebSocket(t, server)
+	writeClientFrame(t, conn, true, CloseMessage, closePayload(CloseGoingAway, "bye"))
+
+	_, opcode, payload := readServerFrame(t, br)
+	if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseGoingAway {
+		t.Errorf("reply opcode=%d payload=%v, want close 1001", opcode, payload)
+	}
+	var closeErr *CloseError
+	if err := <-errc; !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Errorf("ReadMessage error = %v, want close 1001 bye", err)
+	}
+}
+
+func TestWebSocketReadLimit(t *testing.T) {
+	for _, limit := range []int64{4, 0} {
+		errc := make(chan error, 1)
+		server := newWebSocketServer(t, WebSocketConfig{}, func(conn *Conn) {
+			conn.SetReadLimit(limit)
+			_, _, err := conn.ReadMessage()
+			errc <- err
+		})
+		conn, br, _ := dialWebSocket(t, server)
+		if limit > 0 {
+			writeClientFrame(t, conn, true, BinaryMessage, []byte("0123456789"))
+		} else {
+			//只发送帧头 声明一个超大的长度 不能因此分配内存
+			head := []byte{0x80 | BinaryMessage, 0x80 | 127}
+			head = binary.BigEndian.AppendUint64(head, 1<<62)
+			conn.Write(head)
+		}
+		if err := <-errc; !errors.Is(err, ErrReadLimit) {
+			t.Errorf("limit %d: ReadMessage error = %v, want ErrReadLimit", limit, err)
+		}
+		_, opcode, payload := readServerFrame(t, br)
+		if opcode != CloseMessage || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+			t.Errorf("limit %d: opcode=%d payload=%v, want close 1009", limit, opcode, payload)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package gee
+
+import (
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//yamlMaxDepth 最大嵌套层数 防止循环引用导致无限递归
+const yamlMaxDepth = 100
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+//marshalYAML 把任意值编码为 YAML 块格式 支持 map、struct(yaml tag)、slice 和基本类型
+func marshalYAML(v interface{}) ([]byte, error) {
+	out, block, err := yamlNode(reflect.ValueOf(v), 0)
+	if err != nil {
+		return nil, err
+	}
+	if !block {
+		out += "\n"
+	}
+	return []byte(out), nil
+}
+
+//yamlNode 返回值的 YAML 表示
+//block 为 false 时 out 是单行标量 否则 out 是从第 0 列开始、以换行结尾的块
+func yamlNode(v reflect.Value, depth int) (out string, block bool, err error) {
+	if depth > yamlMaxDepth {
+		return "", false, errors.New("gee: yaml: exceeded max depth, possible cycle")
+	}
+	//先处理指针和接口 nil 直接编码为 null
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return "null", false, nil
+		}
+		if v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "null", false, nil
+	}
+	//实现了 TextMarshaler 的类型(例如 time.Time)按字符串输出
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", false, err
+		}
+		return yamlString(string(text)), false, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), false, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), false, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), false, nil
+	case reflect.Float32, reflect.Float64:
+		return yamlFloat(v.Float(), v.Type().Bits()), false, nil
+	case reflect.String:
+		return yamlString(v.String()), false, nil
+	case reflect.Map:
+		return yamlMap(v, depth)
+	case reflect.Struct:
+		return yamlStruct(v, depth)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return "[]", false, nil
+		}
+		//[]byte 按 YAML 的 !!binary 编码
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			return "!!binary " + base64.StdEncoding.EncodeToString(v.Bytes()), false, nil
+		}
+		return yamlSequence(v, depth)
+	}
+	return "", false, fmt.Errorf("gee: yaml: unsupported type %s", v.Type())
+}
+
+//yamlEntry 映射中的一个键值对
+type yamlEntry struct {
+	key   string
+	value reflect.Value
+}
+
+func yamlMap(v reflect.Value, depth int) (string, bool, error) {
+	if v.Len() == 0 {
+		return "{}", false, nil
+	}
+	entries := make([]yamlEntry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, block, err := yamlNode(iter.Key(), depth+1)
+		if err != nil {
+			return "", false, err
+		}
+		if block {
+			return "", false, fmt.Errorf("gee: yaml: unsupported map key type %s", iter.Key().Type())
+		}
+		entries = append(entries, yamlEntry{key: key, value: iter.Value()})
+	}
+	//map 的遍历顺序是随机的 排序后输出才稳定
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return yamlMapping(entries, depth)
+}
+
+func yamlStruct(v reflect.Value, depth int) (string, bool, error) {
+	entries := yamlStructEntries(v, nil)
+	if len(entries) == 0 {
+		return "{}", false, nil
+	}
+	return yamlMapping(entries, depth)
+}
+
+//yamlStructEntries 按字段顺序收集结构体的导出字段 匿名结构体字段会被展开
+func yamlStructEntries(v reflect.Value, entries []yamlEntry) []yamlEntry {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				ft, fv = ft.Elem(), fv.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				entries = yamlStructEntries(fv, entries)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if strings.Contains(opts, "omitempty") && fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		entries = append(entries, yamlEntry{key: yamlString(name), value: fv})
+	}
+	return entries
+}
+
+//yamlMapping 把键值对编码为块格式的映射
+func yamlMapping(entries []yamlEntry, depth int) (string, bool, error) {
+	var sb strings.Builder
+	for _, entry := range entries {
+		out, block, err := yamlNode(entry.value, depth+1)
+		if err != nil {
+			return "", false, err
+		}
+		sb.WriteString(entry.key)
+		if !block {
+			sb.WriteString(": ")
+			sb.WriteString(out)
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(":\n")
+		sb.WriteString(yamlIndent(out, "  ", "  "))
+	}
+	return sb.String(), true, nil
+}
+
+//yamlSequence 把数组编码为块格式的序列 例如：- a
+func yamlSequence(v reflect.Value, depth int) (string, bool, error) {
+	if v.Len() == 0 {
+		return "[]", false, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < v.Len(); i++ {
+		out, block, err := yamlNode(v.Index(i), depth+1)
+		if err != nil {
+			return "", false, err
+		}
+		if !block {
+			sb.WriteString("- ")
+			sb.WriteString(out)
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(yamlIndent(out, "- ", "  "))
+	}
+	return sb.String(), true, nil
+}
+
+//yamlIndent 给块的第一行加上 first 前缀 其余行加上 rest 前缀
+func yamlIndent(block string, first string, rest string) string {
+	lines := strings.SplitAfter(block, "\n")
+	var sb strings.Builder
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if i == 0 {
+			sb.WriteString(first)
+		} else {
+			sb.WriteString(rest)
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+func yamlFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	//保证浮点数不会被解析成整数
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
+
+//yamlString 编码字符串 能用 plain 形式就不加引号 否则使用双引号转义
+func yamlString(s string) string {
+	if yamlNeedsQuote(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func yamlNeedsQuote(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	//会被解析成 bool、null 或数字的字符串必须加引号
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~", ".nan", ".inf", "-.inf", "+.inf":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return true
+	}
+	//以指示符开头的字符串会被当成 YAML 语法
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r == '\n' || r == '\r' || r == '\t' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
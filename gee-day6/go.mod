@@ -1,6 +1,6 @@
 module gee-day4
 
-go 1.18
+go 1.21
 replace gee => ./gee
 
 require gee v0.0.0
\ No newline at end of file
@@ -14,7 +14,7 @@ func onlyForV2() gee.HandlerFunc {
 		// if a server error occurred
 		c.Fail(500, "Internal Server Error")
 		// Calculate resolution time
-		log.Printf("[%d] %s in %v for group v2", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		log.Printf("[%d] %s in %v for group v2", c.Writer.Status(), c.Req.RequestURI, time.Since(t))
 	}
 }
 
@@ -24,9 +24,8 @@ type student struct {
 }
 
 func main() {
-	r := gee.New()
+	r := gee.Default() // Logger and Recovery middleware 默认使用Logger和Recovery中间件
 	r.Static("/assets", "./static")
-	r.Use(gee.Logger()) // global middleware 全局中间件
 	r.GET("/", func(c *gee.Context) {
 		c.HTML(http.StatusOK, "css.tmpl", nil)
 	})
@@ -38,15 +37,28 @@ func main() {
 			"stuArr": [2]*student{stu1, stu2},
 		})
 	})
-	r.GET("/hello", func(c *gee.Context) {
-		// expect /hello?name=geektutu
-		time.Sleep(5 * time.Second)
+	// 超时中间件会缓冲响应 只用在普通的请求上 不要用在流式响应和WebSocket上
+	slow := r.Group("/slow")
+	slow.Use(gee.Timeout(10 * time.Second)) // 处理时间超过10秒时返回503
+	slow.GET("/hello", func(c *gee.Context) {
+		// expect /slow/hello?name=geektutu
+		select {
+		case <-time.After(5 * time.Second):
+		case <-c.Done(): // 超时或者客户端断开时不再继续处理
+			return
+		}
 		c.String(http.StatusOK, "hello %s, you're at %s\n", c.Query("name"), c.Path)
 	})
+	// index out of range for testing Recovery()
+	r.GET("/panic", func(c *gee.Context) {
+		names := []string{"geektutu"}
+		c.String(http.StatusOK, names[100])
+	})
 	r.POST("/login", func(c *gee.Context) {
+		//不要把密码原样返回 示例中只返回遮盖后的值
 		c.JSON(http.StatusOK, gee.H{
 			"username": c.PostForm("username"),
-			"password": c.PostForm("password"),
+			"password": r.Redaction().Mask(c.PostForm("password")),
 		})
 	})
 